@@ -1,26 +1,119 @@
 package main
 
 import (
+	"archive/tar"
+	"bufio"
 	"bytes"
+	"compress/gzip"
 	"context"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
-	"os/exec"
 	"flag"
 	"fmt"
 	"io"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
-	"regexp"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"syscall"
 	"time"
 
-	"github.com/ipfs/boxo/path" // This is the correct import for the path type returned by kubo client
-	files "github.com/ipfs/boxo/files" // Use boxo/files for Node type compatibility
-	cid "github.com/ipfs/go-cid"         // Import the go-cid package
-	rpc "github.com/ipfs/kubo/client/rpc" // Renamed import to avoid conflict
+	files "github.com/ipfs/boxo/files"            // Use boxo/files for Node type compatibility
+	"github.com/ipfs/boxo/ipns"                   // Typed IPNS record decode/validate for resolve_ipns_record
+	"github.com/ipfs/boxo/path"                   // This is the correct import for the path type returned by kubo client
+	cid "github.com/ipfs/go-cid"                  // Import the go-cid package
+	rpc "github.com/ipfs/kubo/client/rpc"         // Renamed import to avoid conflict
+	iface "github.com/ipfs/kubo/core/coreiface"   // Pin type returned by PinAPI.Ls
+	"github.com/ipfs/kubo/core/coreiface/options" // Unixfs/Key add/generate options (CidVersion, Pin, KeyType, ...)
+	ci "github.com/libp2p/go-libp2p/core/crypto"
+	"github.com/libp2p/go-libp2p/core/peer"
 	ma "github.com/multiformats/go-multiaddr"
+	mbase "github.com/multiformats/go-multibase"
+	mh "github.com/multiformats/go-multihash"
 )
 
+// cidBase is set from the global --cid-base flag and consulted by encodeCid.
+// Empty means "use each CID's own base" (Kubo's default behavior).
+var cidBase string
+
+// encodeCidString re-encodes s through encodeCid if it parses as a CID
+// (including libp2p-key CIDs such as IPNS key IDs); otherwise it is returned
+// unchanged.
+func encodeCidString(s string) string {
+	decoded, err := cid.Decode(s)
+	if err != nil {
+		return s
+	}
+	return encodeCid(decoded)
+}
+
+// encodeCid renders c according to the global --cid-base flag, matching
+// Kubo's cmdenv.NewCidBaseHandler: CIDv0 is upgraded to CIDv1 before
+// re-encoding, since CIDv0 is only ever expressed in base58btc.
+func encodeCid(c cid.Cid) string {
+	if cidBase == "" {
+		return c.String()
+	}
+	encoder, err := mbase.EncoderByName(cidBase)
+	if err != nil {
+		// An invalid --cid-base was already rejected at startup; fall back to
+		// the CID's default string form rather than silently dropping data.
+		return c.String()
+	}
+	if c.Version() == 0 {
+		c = cid.NewCidV1(c.Type(), c.Hash())
+	}
+	encoded, err := c.StringOfBase(encoder.Encoding())
+	if err != nil {
+		return c.String()
+	}
+	return encoded
+}
+
+// mhashCodeForName resolves a multihash name (as accepted by Kubo's --hash
+// flag, e.g. "sha2-256" or "blake3") to its numeric code, erroring on an
+// unrecognized name rather than silently substituting a different hash
+// function and changing the resulting CID, matching Kubo's own `ipfs add
+// --hash` behavior.
+func mhashCodeForName(name string) (uint64, error) {
+	if code, ok := mh.Names[strings.ToLower(name)]; ok {
+		return code, nil
+	}
+	return 0, fmt.Errorf("unrecognized hash function '%s'", name)
+}
+
+// countingReader wraps an io.Reader and tracks the number of bytes read so
+// far, so progress can be reported from a separate goroutine without racing
+// on each Read() call.
+type countingReader struct {
+	r  io.Reader
+	n  int64
+	mu sync.Mutex
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.mu.Lock()
+	c.n += int64(n)
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *countingReader) BytesRead() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.n
+}
+
 // CommandResponse structure for JSON output
 type CommandResponse struct {
 	Success bool        `json:"success"`
@@ -39,6 +132,13 @@ type IDResponse struct {
 const WrapperVersion = "0.1.0" // Define the wrapper version
 const RequiredIPFSVersion = "0.34.1"
 
+// minPostMigrationWaitReady is the floor applied to --wait-ready after a
+// migration-triggered daemon restart, regardless of the caller's own
+// --wait-ready flag: runRepoMigration restarts the daemon without waiting
+// for it to come back up, so the readiness gate below must actually run
+// rather than depend on the caller separately opting into --wait-ready.
+const minPostMigrationWaitReady = 30 * time.Second
+
 func printJSONResponse(success bool, errorMsg string, data interface{}) {
 	resp := CommandResponse{
 		Success: success,
@@ -60,8 +160,19 @@ func printJSONResponse(success bool, errorMsg string, data interface{}) {
 		fmt.Println(string(jsonBytes))
 	} else {
 		fmt.Fprintln(os.Stderr, string(jsonBytes)) // Print actual error to stderr
-		os.Exit(1) // Exit with error code
+		os.Exit(1)                                 // Exit with error code
+	}
+}
+
+// mustJSON marshals v, falling back to a minimal error payload if marshaling
+// itself fails (used for emitting progress chunks outside printJSONResponse's
+// success/exit-on-error contract).
+func mustJSON(v interface{}) []byte {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return []byte(fmt.Sprintf(`{"success":false,"error":%q}`, err.Error()))
 	}
+	return b
 }
 
 // compareVersions returns true if version1 is less than version2
@@ -127,108 +238,1669 @@ func checkIPFSVersion() error {
 	if installedVersion == "" {
 		return fmt.Errorf("'ipfs version --number' returned empty output. Stderr: %s", stderr.String())
 	}
-	
+
 	// Handle potential "v" prefix, e.g. "v0.34.1"
 	installedVersion = strings.TrimPrefix(installedVersion, "v")
 
+	isOlder, err := compareVersions(installedVersion, RequiredIPFSVersion)
+	if err != nil {
+		return fmt.Errorf("failed to compare IPFS versions (installed: '%s', required: '%s'): %w", installedVersion, RequiredIPFSVersion, err)
+	}
+
+	if isOlder {
+		return fmt.Errorf("installed IPFS version '%s' is older than required version '%s'. Please upgrade your IPFS (Kubo) daemon/CLI to %s or newer", installedVersion, RequiredIPFSVersion, RequiredIPFSVersion)
+	}
+	// fmt.Fprintf(os.Stderr, "Debug: IPFS version check passed. Installed: %s, Required: %s\n", installedVersion, RequiredIPFSVersion) // Optional debug
+	return nil
+}
+
+// kuboDistJSON is the part of https://dist.ipfs.tech/<dist>/v<version>/dist.json
+// read here: each platform's tarball link and sha512, keyed by GOOS then GOARCH.
+type kuboDistJSON struct {
+	Platforms map[string]map[string]struct {
+		Link   string `json:"link"`
+		Sha512 string `json:"sha512"`
+	} `json:"platforms"`
+}
+
+// scipfsCacheDir returns the directory scipfs uses to cache downloaded
+// component binaries (e.g. "kubo", "fs-repo-migrations"), honoring
+// XDG_CACHE_HOME when set.
+func scipfsCacheDir(component string) (string, error) {
+	cacheHome := os.Getenv("XDG_CACHE_HOME")
+	if cacheHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not determine home directory: %w", err)
+		}
+		cacheHome = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(cacheHome, "scipfs", component), nil
+}
+
+// fetchLatestKuboVersion queries dist.ipfs.tech for the list of published
+// Kubo versions and returns the latest one that is >= minVersion.
+func fetchLatestKuboVersion(ctx context.Context, minVersion string) (string, error) {
+	return fetchLatestDistVersion(ctx, "kubo", minVersion)
+}
+
+// fetchLatestDistVersion queries https://dist.ipfs.tech/<distName>/versions
+// and returns the highest published version >= minVersion. Pass "0.0.0" for
+// minVersion to just get the latest version published, with no floor.
+func fetchLatestDistVersion(ctx context.Context, distName string, minVersion string) (string, error) {
+	versionsURL := fmt.Sprintf("https://dist.ipfs.tech/%s/versions", distName)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, versionsURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build request for %s versions list: %w", distName, err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch %s versions list: %w", distName, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d fetching %s versions list", resp.StatusCode, distName)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s versions list: %w", distName, err)
+	}
+
+	best := ""
+	for _, line := range strings.Split(strings.TrimSpace(string(body)), "\n") {
+		v := strings.TrimSpace(strings.TrimPrefix(line, "v"))
+		if v == "" {
+			continue
+		}
+		older, err := compareVersions(v, minVersion)
+		if err != nil || older {
+			continue
+		}
+		if best == "" {
+			best = v
+			continue
+		}
+		if olderThanBest, err := compareVersions(v, best); err == nil && !olderThanBest {
+			best = v
+		}
+	}
+	if best == "" {
+		return "", fmt.Errorf("no published %s version >= %s found", distName, minVersion)
+	}
+	return best, nil
+}
+
+// downloadAndVerifyKubo downloads the kubo tarball for the given version and
+// current GOOS/GOARCH, verifies its sha512 against dist.ipfs.tech's dist.json,
+// unpacks the ipfs binary, and returns the path to the cached binary.
+func downloadAndVerifyKubo(ctx context.Context, version string, onProgress func(msg string)) (string, error) {
+	return downloadAndVerifyDistTarball(ctx, "kubo", version, "ipfs", onProgress)
+}
+
+// downloadAndVerifyDistTarball downloads the <distName>_v<version>_<goos>-<goarch>.tar.gz
+// tarball published under https://dist.ipfs.tech/<distName>/v<version>/, verifies
+// its sha512 against that version's dist.json, unpacks the file named binName
+// out of it into a scipfs-managed cache directory, and returns the path to
+// the cached binary. Shared by the kubo binary fetch and the fs-repo-migrations
+// fetch, which are published under the same dist.ipfs.tech layout.
+func downloadAndVerifyDistTarball(ctx context.Context, distName string, version string, binName string, onProgress func(msg string)) (string, error) {
+	goos, goarch := runtime.GOOS, runtime.GOARCH
+	tarballName := fmt.Sprintf("%s_v%s_%s-%s.tar.gz", distName, version, goos, goarch)
+	distJSONURL := fmt.Sprintf("https://dist.ipfs.tech/%s/v%s/dist.json", distName, version)
+	tarballURL := fmt.Sprintf("https://dist.ipfs.tech/%s/v%s/%s", distName, version, tarballName)
+
+	cacheDir, err := scipfsCacheDir(distName)
+	if err != nil {
+		return "", err
+	}
+	versionDir := filepath.Join(cacheDir, version)
+	binPath := filepath.Join(versionDir, binName)
+	if _, err := os.Stat(binPath); err == nil {
+		return binPath, nil
+	}
+
+	onProgress(fmt.Sprintf("fetching dist.json for %s %s", distName, version))
+	distReq, err := http.NewRequestWithContext(ctx, http.MethodGet, distJSONURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build dist.json request: %w", err)
+	}
+	distResp, err := http.DefaultClient.Do(distReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch dist.json: %w", err)
+	}
+	defer distResp.Body.Close()
+	var dist kuboDistJSON
+	if err := json.NewDecoder(distResp.Body).Decode(&dist); err != nil {
+		return "", fmt.Errorf("failed to decode dist.json: %w", err)
+	}
+	platform, ok := dist.Platforms[goos]
+	if !ok {
+		return "", fmt.Errorf("no %s %s build published for platform %s", distName, version, goos)
+	}
+	archEntry, ok := platform[goarch]
+	if !ok {
+		return "", fmt.Errorf("no %s %s build published for %s/%s", distName, version, goos, goarch)
+	}
+
+	onProgress(fmt.Sprintf("downloading %s", tarballURL))
+	tarReq, err := http.NewRequestWithContext(ctx, http.MethodGet, tarballURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to build tarball request: %w", err)
+	}
+	tarResp, err := http.DefaultClient.Do(tarReq)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %w", tarballURL, err)
+	}
+	defer tarResp.Body.Close()
+	if tarResp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %d downloading %s", tarResp.StatusCode, tarballURL)
+	}
+
+	tarballBytes, err := io.ReadAll(tarResp.Body)
+	if err != nil {
+		return "", fmt.Errorf("failed to read downloaded tarball: %w", err)
+	}
+	// dist.ipfs.tech publishes sha512, not sha256, for each platform's
+	// tarball; verify against that so an unverified binary is never unpacked
+	// and added to PATH for use by every subsequent exec.Command call.
+	if archEntry.Sha512 == "" {
+		return "", fmt.Errorf("dist.json for %s %s/%s does not publish a sha512 checksum, refusing to use an unverified binary", distName, goos, goarch)
+	}
+	sum := sha512.Sum512(tarballBytes)
+	gotSum := hex.EncodeToString(sum[:])
+	if !strings.EqualFold(gotSum, archEntry.Sha512) {
+		return "", fmt.Errorf("sha512 mismatch for %s: expected %s, got %s", tarballURL, archEntry.Sha512, gotSum)
+	}
+
+	onProgress(fmt.Sprintf("unpacking %s binary", binName))
+	if err := os.MkdirAll(versionDir, 0o755); err != nil {
+		return "", fmt.Errorf("failed to create cache dir %s: %w", versionDir, err)
+	}
+	gzr, err := gzip.NewReader(bytes.NewReader(tarballBytes))
+	if err != nil {
+		return "", fmt.Errorf("failed to open tarball gzip stream: %w", err)
+	}
+	defer gzr.Close()
+	tr := tar.NewReader(gzr)
+	found := false
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed reading tarball entries: %w", err)
+		}
+		if filepath.Base(hdr.Name) != binName || hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		out, err := os.OpenFile(binPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o755)
+		if err != nil {
+			return "", fmt.Errorf("failed to create %s: %w", binPath, err)
+		}
+		if _, err := io.Copy(out, tr); err != nil {
+			out.Close()
+			return "", fmt.Errorf("failed to write %s: %w", binPath, err)
+		}
+		out.Close()
+		found = true
+		break
+	}
+	if !found {
+		return "", fmt.Errorf("%s binary not found inside %s", binName, tarballName)
+	}
+	return binPath, nil
+}
+
+// runRepoMigration reads $IPFS_PATH/version (falling back to ~/.ipfs/version),
+// and, if it differs from the version the target ipfs binary expects, shuts down
+// the daemon at apiAddrStr, fetches the consolidated fs-repo-migrations
+// binary (installing it from dist.ipfs.tech if it isn't already on PATH) and
+// runs it against the repo, then restarts the daemon using ipfsBinPath so
+// the caller's subsequent rpc.NewApi connection has something to talk to.
+func runRepoMigration(ctx context.Context, apiAddrStr string, ipfsBinPath string, onProgress func(msg string)) error {
+	repoPath := os.Getenv("IPFS_PATH")
+	if repoPath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return fmt.Errorf("IPFS_PATH not set and could not determine home directory: %w", err)
+		}
+		repoPath = filepath.Join(home, ".ipfs")
+	}
+	versionFile := filepath.Join(repoPath, "version")
+	raw, err := os.ReadFile(versionFile)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// No repo initialized yet; nothing to migrate.
+			return nil
+		}
+		return fmt.Errorf("failed to read repo version file %s: %w", versionFile, err)
+	}
+	fromRepoVer := strings.TrimSpace(string(raw))
+
+	out, err := exec.CommandContext(ctx, ipfsBinPath, "repo", "fsck").CombinedOutput()
+	_ = out // repo fsck output isn't needed; the command is only used to validate the binary is functional.
+	if err != nil {
+		onProgress("warning: 'ipfs repo fsck' reported issues before migration")
+	}
+
+	toRepoVerOut, err := exec.CommandContext(ctx, ipfsBinPath, "repo", "version", "--quiet").Output()
+	if err != nil {
+		return fmt.Errorf("failed to determine target repo version from %s: %w", ipfsBinPath, err)
+	}
+	toRepoVer := strings.TrimSpace(string(toRepoVerOut))
+	if toRepoVer == "" || toRepoVer == fromRepoVer {
+		return nil
+	}
+
+	apiMaddr, err := ma.NewMultiaddr(apiAddrStr)
+	if err != nil {
+		return fmt.Errorf("invalid API multiaddress '%s': %w", apiAddrStr, err)
+	}
+	apiBaseURL, err := httpURLForMultiaddr(apiMaddr)
+	if err != nil {
+		return fmt.Errorf("could not derive shutdown URL from '%s': %w", apiAddrStr, err)
+	}
+
+	onProgress(fmt.Sprintf("migrating repo from version %s to %s", fromRepoVer, toRepoVer))
+
+	shutdownCtx, shutdownCancel := context.WithTimeout(ctx, 15*time.Second)
+	defer shutdownCancel()
+	shutdownReq, err := http.NewRequestWithContext(shutdownCtx, http.MethodPost, apiBaseURL+"/api/v0/shutdown", nil)
+	if err == nil {
+		if resp, err := http.DefaultClient.Do(shutdownReq); err == nil {
+			resp.Body.Close()
+		}
+	}
+
+	migrationPath, lookErr := exec.LookPath("fs-repo-migrations")
+	if lookErr != nil {
+		onProgress("fs-repo-migrations not found on PATH, downloading it")
+		latestMigrations, fetchErr := fetchLatestDistVersion(ctx, "fs-repo-migrations", "0.0.0")
+		if fetchErr != nil {
+			return fmt.Errorf("failed to determine latest fs-repo-migrations version: %w", fetchErr)
+		}
+		migrationPath, err = downloadAndVerifyDistTarball(ctx, "fs-repo-migrations", latestMigrations, "fs-repo-migrations", onProgress)
+		if err != nil {
+			return fmt.Errorf("failed to install fs-repo-migrations %s: %w", latestMigrations, err)
+		}
+	}
+	migrateCmd := exec.CommandContext(ctx, migrationPath, "-to", toRepoVer, "-y")
+	migrateCmd.Env = append(os.Environ(), "IPFS_PATH="+repoPath)
+	if out, err := migrateCmd.CombinedOutput(); err != nil {
+		return fmt.Errorf("migration to %s failed: %w. Output: %s", toRepoVer, err, string(out))
+	}
+	onProgress(fmt.Sprintf("repo migration to version %s complete", toRepoVer))
+
+	onProgress("restarting ipfs daemon")
+	daemonCmd := exec.Command(ipfsBinPath, "daemon")
+	daemonCmd.Env = append(os.Environ(), "IPFS_PATH="+repoPath)
+	if err := daemonCmd.Start(); err != nil {
+		return fmt.Errorf("migration succeeded but failed to restart the daemon at %s: %w", ipfsBinPath, err)
+	}
+	// Deliberately not Wait()-ing: the daemon is a long-running process that
+	// must outlive this call. main() forces --wait-ready up to at least
+	// minPostMigrationWaitReady after a migration so the readiness gate
+	// actually confirms the daemon comes back up, rather than depending on
+	// the caller separately opting into --wait-ready.
+	return nil
+}
+
+// --- Cluster-style multi-daemon pin coordination ---
+//
+// This is a lightweight analogue of ipfs-cluster's pin tracking: a JSON list
+// of trusted peer daemons persisted under $XDG_CONFIG_HOME/scipfs/cluster.json,
+// and a per-CID pin tracker persisted alongside it in pintracker.json. The
+// cluster_* subcommands run one-shot only (the daemon subcommand does not serve
+// them), so "tracking" here just means recording the last observed status per
+// peer each time cluster_pin/cluster_status/cluster_recover run.
+
+// scipfsConfigDir returns the directory scipfs uses for cluster configuration,
+// honoring XDG_CONFIG_HOME when set.
+func scipfsConfigDir() (string, error) {
+	configHome := os.Getenv("XDG_CONFIG_HOME")
+	if configHome == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("could not determine home directory: %w", err)
+		}
+		configHome = filepath.Join(home, ".config")
+	}
+	return filepath.Join(configHome, "scipfs"), nil
+}
+
+func clusterPeersPath() (string, error) {
+	dir, err := scipfsConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "cluster.json"), nil
+}
+
+func pinTrackerPath() (string, error) {
+	dir, err := scipfsConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "pintracker.json"), nil
+}
+
+// loadClusterPeers reads the trusted peer multiaddress list, returning an
+// empty (not nil) slice if the file does not exist yet.
+func loadClusterPeers() ([]string, error) {
+	path, err := clusterPeersPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read cluster peers file %s: %w", path, err)
+	}
+	var peers []string
+	if err := json.Unmarshal(raw, &peers); err != nil {
+		return nil, fmt.Errorf("failed to parse cluster peers file %s: %w", path, err)
+	}
+	return peers, nil
+}
+
+func saveClusterPeers(peers []string) error {
+	path, err := clusterPeersPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cluster config dir for %s: %w", path, err)
+	}
+	raw, err := json.MarshalIndent(peers, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal cluster peers: %w", err)
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+// peerPinStatus is the per-peer status recorded in pintracker.json for a
+// single CID, mirroring ipfs-cluster's pinned|pinning|error|queued states.
+type peerPinStatus struct {
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// loadPinTracker reads the full { cid: { peerAddr: status } } map.
+func loadPinTracker() (map[string]map[string]peerPinStatus, error) {
+	path, err := pinTrackerPath()
+	if err != nil {
+		return nil, err
+	}
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return map[string]map[string]peerPinStatus{}, nil
+		}
+		return nil, fmt.Errorf("failed to read pin tracker file %s: %w", path, err)
+	}
+	tracker := map[string]map[string]peerPinStatus{}
+	if err := json.Unmarshal(raw, &tracker); err != nil {
+		return nil, fmt.Errorf("failed to parse pin tracker file %s: %w", path, err)
+	}
+	return tracker, nil
+}
+
+func savePinTracker(tracker map[string]map[string]peerPinStatus) error {
+	path, err := pinTrackerPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("failed to create cluster config dir for %s: %w", path, err)
+	}
+	raw, err := json.MarshalIndent(tracker, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal pin tracker: %w", err)
+	}
+	return os.WriteFile(path, raw, 0o644)
+}
+
+// kuboHTTPClient is a minimal client for Kubo's HTTP RPC API
+// (https://docs.ipfs.tech/reference/kubo/rpc/), modeled on the ipfs-cluster
+// project's ipfshttp connector. It exists so read-path subcommands that don't
+// need the full coreiface surface (list_pinned_cids, dht_find_providers) can
+// decode Kubo's JSON directly instead of shelling out to the `ipfs` binary
+// and regex/strings.Fields-parsing its human-readable text output, which
+// breaks whenever Kubo changes its CLI phrasing.
+type kuboHTTPClient struct {
+	baseURL    string
+	httpClient *http.Client
+}
+
+// newKuboHTTPClient builds a kuboHTTPClient for the API multiaddress also
+// used to construct the coreiface node (e.g. /ip4/127.0.0.1/tcp/5001).
+func newKuboHTTPClient(apiMaddr ma.Multiaddr, timeout time.Duration) (*kuboHTTPClient, error) {
+	baseURL, err := httpURLForMultiaddr(apiMaddr)
+	if err != nil {
+		return nil, err
+	}
+	return &kuboHTTPClient{
+		baseURL:    baseURL,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// httpURLForMultiaddr converts an /ip4|ip6|dns4|dns6/.../tcp/<port> API
+// multiaddress into an "http://host:port" base URL.
+func httpURLForMultiaddr(maddr ma.Multiaddr) (string, error) {
+	var host, port string
+	ma.ForEach(maddr, func(c ma.Component) bool {
+		switch c.Protocol().Code {
+		case ma.P_IP4, ma.P_DNS4, ma.P_DNS, ma.P_DNS6:
+			host = c.Value()
+		case ma.P_IP6:
+			host = "[" + c.Value() + "]"
+		case ma.P_TCP:
+			port = c.Value()
+		}
+		return true
+	})
+	if host == "" || port == "" {
+		return "", fmt.Errorf("multiaddress %q does not resolve to an HTTP host:port", maddr.String())
+	}
+	return fmt.Sprintf("http://%s:%s", host, port), nil
+}
+
+// do issues a POST to /api/v0/<path>?<query>, the method Kubo's RPC API
+// requires for all commands, and returns the raw response body for the
+// caller to decode (streamed NDJSON commands like routing/findprovs don't
+// fit a single json.Unmarshal).
+func (k *kuboHTTPClient) do(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	reqURL := k.baseURL + "/api/v0/" + path
+	if len(query) > 0 {
+		reqURL += "?" + query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, reqURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("building request for %s: %w", path, err)
+	}
+	resp, err := k.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("calling %s: %w", path, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("kubo API %s returned status %d: %s", path, resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// pinLsStreamEntry is one line of Kubo's pin/ls response when called with
+// stream=true&names=true.
+type pinLsStreamEntry struct {
+	Cid  string `json:"Cid"`
+	Type string `json:"Type"`
+	Name string `json:"Name"`
+}
+
+// PinLsStream calls pin/ls with stream=true&names=true and returns the raw
+// response for the caller to decode one entry at a time via
+// json.Decoder.Decode, so a repo with hundreds of thousands of pins doesn't
+// have to be buffered into memory (or parsed from human-readable text) all
+// at once the way the old `ipfs pin ls` exec-and-scan approach did. The
+// caller is responsible for closing the response body.
+func (k *kuboHTTPClient) PinLsStream(ctx context.Context, pinType string) (*http.Response, error) {
+	return k.do(ctx, "pin/ls", url.Values{
+		"type":   {pinType},
+		"stream": {"true"},
+		"names":  {"true"},
+	})
+}
+
+// queryEventProvider is routing.Provider from Kubo's routing/findprovs NDJSON
+// event stream (QueryEventType in github.com/libp2p/go-libp2p/core/routing);
+// it's the only event type that carries a provider's peer ID.
+const queryEventProvider = 4
+
+// RoutingFindProvs streams routing/findprovs and returns the peer IDs of up
+// to maxProviders providers found for c.
+func (k *kuboHTTPClient) RoutingFindProvs(ctx context.Context, c cid.Cid, maxProviders int) ([]string, error) {
+	resp, err := k.do(ctx, "routing/findprovs", url.Values{
+		"arg":           {c.String()},
+		"num-providers": {strconv.Itoa(maxProviders)},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var providers []string
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var event struct {
+			Type      int `json:"Type"`
+			Responses []struct {
+				ID string `json:"ID"`
+			} `json:"Responses"`
+		}
+		if err := dec.Decode(&event); err != nil {
+			return nil, fmt.Errorf("decoding routing/findprovs event: %w", err)
+		}
+		if event.Type == queryEventProvider {
+			for _, r := range event.Responses {
+				if r.ID != "" {
+					providers = append(providers, r.ID)
+				}
+			}
+		}
+	}
+	return providers, nil
+}
+
+// SwarmConnect dials addr (a full multiaddr, or "/p2p/<peerID>" to rely on
+// the routing system to discover addresses), mirroring `ipfs swarm connect`.
+func (k *kuboHTTPClient) SwarmConnect(ctx context.Context, addr string) error {
+	resp, err := k.do(ctx, "swarm/connect", url.Values{"arg": {addr}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// PinAdd recursively pins c, mirroring `ipfs pin add`.
+func (k *kuboHTTPClient) PinAdd(ctx context.Context, c cid.Cid) error {
+	resp, err := k.do(ctx, "pin/add", url.Values{"arg": {c.String()}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return nil
+}
+
+// fetchIPNSRecord fetches and decodes the raw signed IPNS record published
+// under ipnsName, returning the decoded record alongside the peer ID it was
+// published under (needed to recover the record's public key for
+// signature verification).
+func fetchIPNSRecord(ctx context.Context, node *rpc.HttpApi, ipnsName string) (*ipns.Record, peer.ID, error) {
+	trimmed := strings.TrimPrefix(ipnsName, "/ipns/")
+	pid, err := peer.Decode(trimmed)
+	if err != nil {
+		return nil, "", fmt.Errorf("'%s' is not a valid IPNS name (peer ID): %w", ipnsName, err)
+	}
+
+	// There is no typed CoreAPI method for fetching a raw IPNS record, so we
+	// fall back to the generic RPC request, the same pattern used for the
+	// startup 'id' check.
+	var routingGetResp bytes.Buffer
+	if err := node.Request("routing/get", "/ipns/"+trimmed).Exec(ctx, &routingGetResp); err != nil {
+		return nil, "", fmt.Errorf("failed to fetch raw IPNS record for '%s': %w", ipnsName, err)
+	}
+
+	rec, err := ipns.UnmarshalRecord(routingGetResp.Bytes())
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode IPNS record for '%s': %w", ipnsName, err)
+	}
+	return rec, pid, nil
+}
+
+// inspectIPNS fetches, decodes, and verifies the IPNS record published under
+// ipnsName, returning the same field set surfaced by the inspect_ipns
+// subcommand. It fails if the record's sequence number is below minSequence,
+// which lets a caller reject a rolled-back or stale-cached record. Shared by
+// the inspect_ipns one-shot case and the daemon subcommand dispatcher.
+func inspectIPNS(ctx context.Context, node *rpc.HttpApi, ipnsName string, minSequence uint64) (map[string]interface{}, error) {
+	rec, pid, err := fetchIPNSRecord(ctx, node, ipnsName)
+	if err != nil {
+		return nil, err
+	}
+
+	seq, seqErr := rec.Sequence()
+	validity, validityErr := rec.Validity()
+	validityType, validityTypeErr := rec.ValidityType()
+	ttlVal, ttlErr := rec.TTL()
+	value, valueErr := rec.Value()
+	if seqErr != nil || validityErr != nil || validityTypeErr != nil || ttlErr != nil || valueErr != nil {
+		return nil, fmt.Errorf("IPNS record for '%s' is missing required fields", ipnsName)
+	}
+
+	if seq < minSequence {
+		return nil, fmt.Errorf("resolved record sequence %d for '%s' is below required minimum %d (possible rollback or stale cache)", seq, ipnsName, minSequence)
+	}
+
+	var publicKeyB64 string
+	signatureValid := false
+	if pubKey, pkErr := ipns.ExtractPublicKey(rec, ipns.NameFromPeer(pid)); pkErr == nil {
+		if rawPubKey, marshalErr := ci.MarshalPublicKey(pubKey); marshalErr == nil {
+			publicKeyB64 = base64.StdEncoding.EncodeToString(rawPubKey)
+		}
+		signatureValid = ipns.Validate(rec, pubKey) == nil
+	}
+
+	return map[string]interface{}{
+		"Value":          value.String(),
+		"Sequence":       seq,
+		"Validity":       validity.Format(time.RFC3339),
+		"ValidityType":   ipnsValidityTypeString(validityType),
+		"TTL":            ttlVal.String(),
+		"PublicKey":      publicKeyB64,
+		"SignatureValid": signatureValid,
+	}, nil
+}
+
+// ipnsValidityTypeString renders an ipns.ValidityType the way Kubo's own CLI
+// does; ipns.ValidityEOL ("valid until {Validity}") is the only type IPNS
+// records currently support, so anything else is an unexpected raw value.
+func ipnsValidityTypeString(vt ipns.ValidityType) string {
+	if vt == ipns.ValidityEOL {
+		return "EOL"
+	}
+	return fmt.Sprintf("unknown(%d)", int64(vt))
+}
+
+// pinCID pins the given CID at /ipfs/<cid>, returning the same field set the
+// pin subcommand prints. Shared by the one-shot pin case and the daemon
+// subcommand dispatcher so the two can't drift.
+func pinCID(ctx context.Context, node *rpc.HttpApi, cidStr string) (map[string]string, error) {
+	if _, err := cid.Decode(cidStr); err != nil {
+		return nil, fmt.Errorf("invalid CID format for '%s': %w", cidStr, err)
+	}
+	ipfsPathStr := "/ipfs/" + cidStr
+	p, err := path.NewPath(ipfsPathStr)
+	if err != nil {
+		return nil, fmt.Errorf("error creating IPFS path object for '%s': %w", ipfsPathStr, err)
+	}
+	if err := node.Pin().Add(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to pin IPFS path '%s': %w", ipfsPathStr, err)
+	}
+	return map[string]string{"cid": encodeCidString(cidStr), "path": ipfsPathStr, "status": "pinned"}, nil
+}
+
+// resolveIPNSName resolves an IPNS name to its target path, returning the
+// same field set the resolve_ipns subcommand prints. Shared by the one-shot
+// resolve_ipns case and the daemon subcommand dispatcher.
+func resolveIPNSName(ctx context.Context, node *rpc.HttpApi, ipnsName string, nocache bool) (map[string]string, error) {
+	ipnsPathStr := ipnsName
+	if !strings.HasPrefix(ipnsPathStr, "/ipns/") {
+		ipnsPathStr = "/ipns/" + ipnsPathStr
+	}
+	resolved, err := node.Name().Resolve(ctx, ipnsPathStr, options.Name.Cache(!nocache))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve IPNS name '%s': %w", ipnsName, err)
+	}
+	return map[string]string{"Path": resolved.String()}, nil
+}
+
+// findDHTProviders looks up providers for decodedCid via the Kubo API client,
+// normalizing a nil result to an empty (not nil) slice. Shared by the
+// one-shot dht_find_providers case and the daemon subcommand dispatcher.
+func findDHTProviders(ctx context.Context, kuboClient *kuboHTTPClient, decodedCid cid.Cid, numProviders int) ([]string, error) {
+	providers, err := kuboClient.RoutingFindProvs(ctx, decodedCid, numProviders)
+	if err != nil {
+		return nil, err
+	}
+	if providers == nil {
+		providers = []string{}
+	}
+	return providers, nil
+}
+
+// listPinnedCIDs lists pins of filterType, narrowing client-side to names
+// matching the filterName glob pattern (matching everything when empty), and
+// returns them keyed by CID the same way the non-streaming list_pinned_cids
+// subcommand does. Shared by that one-shot case and the daemon subcommand
+// dispatcher; --stream mode prints incrementally and isn't supported in
+// daemon mode, so it has no analogue here.
+func listPinnedCIDs(ctx context.Context, kuboClient *kuboHTTPClient, filterType string, filterName string) (map[string]map[string]string, error) {
+	resp, err := kuboClient.PinLsStream(ctx, filterType)
+	if err != nil {
+		return nil, fmt.Errorf("error listing pins of type '%s': %w", filterType, err)
+	}
+	defer resp.Body.Close()
+
+	matches := func(name string) bool {
+		if filterName == "" {
+			return true
+		}
+		ok, matchErr := filepath.Match(filterName, name)
+		return matchErr == nil && ok
+	}
+
+	cidsWithTypes := make(map[string]map[string]string)
+	dec := json.NewDecoder(resp.Body)
+	for dec.More() {
+		var entry pinLsStreamEntry
+		if err := dec.Decode(&entry); err != nil {
+			return nil, fmt.Errorf("error decoding pin/ls stream for type '%s': %w", filterType, err)
+		}
+		if !matches(entry.Name) {
+			continue
+		}
+		cidsWithTypes[encodeCidString(entry.Cid)] = map[string]string{"Type": entry.Type, "Name": entry.Name}
+	}
+	return cidsWithTypes, nil
+}
+
+// defaultSocketPath returns the default Unix domain socket path for the
+// daemon subcommand: $XDG_RUNTIME_DIR/scipfs.sock, falling back to a path
+// under os.TempDir() when XDG_RUNTIME_DIR isn't set (e.g. non-systemd hosts).
+func defaultSocketPath() string {
+	if runtimeDir := os.Getenv("XDG_RUNTIME_DIR"); runtimeDir != "" {
+		return filepath.Join(runtimeDir, "scipfs.sock")
+	}
+	return filepath.Join(os.TempDir(), "scipfs.sock")
+}
+
+// daemonRequest is one line of the daemon subcommand's newline-delimited
+// JSON protocol: { "id": "...", "subcommand": "...", "args": {...} }.
+// CidBase carries the calling client's own --cid-base flag (empty if unset)
+// so handleDaemonConn can catch a mismatch against the base the daemon
+// process itself started with, rather than silently encoding CIDs in the
+// daemon's base regardless of what this client asked for.
+type daemonRequest struct {
+	ID         string                 `json:"id"`
+	Subcommand string                 `json:"subcommand"`
+	Args       map[string]interface{} `json:"args"`
+	CidBase    string                 `json:"cid_base,omitempty"`
+}
+
+// daemonReply echoes the request's id alongside the usual CommandResponse
+// envelope, so a client pipelining multiple in-flight requests over the same
+// connection can match replies back to requests.
+type daemonReply struct {
+	ID string `json:"id"`
+	CommandResponse
+}
+
+// daemonStringArg reads a string argument out of a daemon request's Args map.
+func daemonStringArg(args map[string]interface{}, key, fallback string) string {
+	if v, ok := args[key]; ok {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+	return fallback
+}
+
+// daemonIntArg reads an integer argument out of a daemon request's Args map;
+// JSON numbers decode as float64, so it's converted explicitly.
+func daemonIntArg(args map[string]interface{}, key string, fallback int) int {
+	if v, ok := args[key]; ok {
+		if f, ok := v.(float64); ok {
+			return int(f)
+		}
+	}
+	return fallback
+}
+
+// dispatchDaemon handles one daemon-mode request by calling the same
+// business-logic helpers the one-shot subcommands use (kuboHTTPClient,
+// node.Name(), node.Pin(), fetchIPNSRecord/inspectIPNS), and is the
+// `Dispatch(subcommand, args)` entry point the daemon subcommand's protocol
+// is built around. Unlike the one-shot switch in main(), this never calls
+// printJSONResponse or os.Exit: a bad request from one client must not bring
+// down a long-running daemon serving other clients. Only the handful of
+// subcommands that dominate the fork+exec overhead in practice (repeatedly
+// listing pins, finding providers, and resolving IPNS names in a workflow)
+// are wired up here; anything else gets a clear "not supported in daemon
+// mode" error rather than a silent fork back to exec.
+func dispatchDaemon(ctx context.Context, node *rpc.HttpApi, apiAddrStr string, subcommand string, args map[string]interface{}) CommandResponse {
+	switch subcommand {
+	case "list_pinned_cids":
+		filterType := daemonStringArg(args, "filter-type", "recursive")
+		filterName := daemonStringArg(args, "filter-name", "")
+		apiMaddr, err := ma.NewMultiaddr(apiAddrStr)
+		if err != nil {
+			return CommandResponse{Error: fmt.Sprintf("Invalid API multiaddress '%s': %s", apiAddrStr, err.Error())}
+		}
+		kuboClient, err := newKuboHTTPClient(apiMaddr, 0) // streamed, so no fixed client-side timeout
+		if err != nil {
+			return CommandResponse{Error: fmt.Sprintf("Failed to build IPFS API client: %s", err.Error())}
+		}
+		cidsWithTypes, err := listPinnedCIDs(ctx, kuboClient, filterType, filterName)
+		if err != nil {
+			return CommandResponse{Error: err.Error()}
+		}
+		return CommandResponse{Success: true, Data: cidsWithTypes}
+
+	case "dht_find_providers":
+		cidStr := daemonStringArg(args, "cid", "")
+		if cidStr == "" {
+			return CommandResponse{Error: "Argument 'cid' is required"}
+		}
+		decodedCid, err := cid.Decode(cidStr)
+		if err != nil {
+			return CommandResponse{Success: true, Data: map[string][]string{"providers": {}}}
+		}
+		apiMaddr, err := ma.NewMultiaddr(apiAddrStr)
+		if err != nil {
+			return CommandResponse{Error: fmt.Sprintf("Invalid API multiaddress '%s': %s", apiAddrStr, err.Error())}
+		}
+		kuboClient, err := newKuboHTTPClient(apiMaddr, 60*time.Second)
+		if err != nil {
+			return CommandResponse{Error: fmt.Sprintf("Failed to build IPFS API client: %s", err.Error())}
+		}
+		providers, err := findDHTProviders(ctx, kuboClient, decodedCid, daemonIntArg(args, "num-providers", 20))
+		if err != nil {
+			return CommandResponse{Error: fmt.Sprintf("Error finding providers for '%s': %s", cidStr, err.Error())}
+		}
+		return CommandResponse{Success: true, Data: map[string][]string{"providers": providers}}
+
+	case "resolve_ipns":
+		ipnsName := daemonStringArg(args, "ipns-name", "")
+		if ipnsName == "" {
+			return CommandResponse{Error: "Argument 'ipns-name' is required"}
+		}
+		nocache := true
+		if v, ok := args["nocache"].(bool); ok {
+			nocache = v
+		}
+		data, err := resolveIPNSName(ctx, node, ipnsName, nocache)
+		if err != nil {
+			return CommandResponse{Error: err.Error()}
+		}
+		return CommandResponse{Success: true, Data: data}
+
+	case "inspect_ipns":
+		ipnsName := daemonStringArg(args, "ipns-name", "")
+		if ipnsName == "" {
+			return CommandResponse{Error: "Argument 'ipns-name' is required"}
+		}
+		minSequence := uint64(daemonIntArg(args, "min-sequence", 0))
+		data, err := inspectIPNS(ctx, node, ipnsName, minSequence)
+		if err != nil {
+			return CommandResponse{Error: err.Error()}
+		}
+		return CommandResponse{Success: true, Data: data}
+
+	case "pin":
+		cidStr := daemonStringArg(args, "cid", "")
+		if cidStr == "" {
+			return CommandResponse{Error: "Argument 'cid' is required"}
+		}
+		data, err := pinCID(ctx, node, cidStr)
+		if err != nil {
+			return CommandResponse{Error: err.Error()}
+		}
+		return CommandResponse{Success: true, Data: data}
+
+	default:
+		return CommandResponse{Error: fmt.Sprintf("subcommand '%s' is not supported in daemon mode; use one-shot invocation instead", subcommand)}
+	}
+}
+
+// handleDaemonConn services one client connection to the daemon socket: each
+// line is a daemonRequest, each reply a daemonReply written back immediately,
+// so a client can pipeline several requests without waiting for replies.
+func handleDaemonConn(node *rpc.HttpApi, apiAddrStr string, conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	enc := json.NewEncoder(conn)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var req daemonRequest
+		if err := json.Unmarshal([]byte(line), &req); err != nil {
+			enc.Encode(daemonReply{CommandResponse: CommandResponse{Error: fmt.Sprintf("invalid request JSON: %s", err.Error())}})
+			continue
+		}
+		if req.CidBase != cidBase {
+			enc.Encode(daemonReply{ID: req.ID, CommandResponse: CommandResponse{Error: fmt.Sprintf(
+				"daemon was started with --cid-base %q but this request used %q; restart the daemon with a matching --cid-base or drop --cid-base from the --daemon call",
+				cidBase, req.CidBase)}})
+			continue
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 120*time.Second)
+		resp := dispatchDaemon(ctx, node, apiAddrStr, req.Subcommand, req.Args)
+		cancel()
+		enc.Encode(daemonReply{ID: req.ID, CommandResponse: resp})
+	}
+}
+
+// daemonClientSubcommands lists the subcommands dispatchDaemon knows how to
+// handle, so tryDaemonSocket can recognize up front which invocations are
+// even worth routing over the socket.
+var daemonClientSubcommands = map[string]bool{
+	"list_pinned_cids": true, "dht_find_providers": true, "resolve_ipns": true,
+	"inspect_ipns": true, "pin": true,
+}
+
+// daemonClientArgs re-parses subcommandArgs with the same flags the one-shot
+// case for subcommand uses, and returns them as the map[string]interface{}
+// dispatchDaemon expects. ok is false when subcommand isn't one
+// tryDaemonSocket should handle over the socket (e.g. an unrecognized
+// subcommand, or flags like --stream that the single-reply protocol can't
+// support), in which case the caller falls back to the normal exec-per-call
+// path.
+func daemonClientArgs(subcommand string, subcommandArgs []string) (map[string]interface{}, bool) {
+	switch subcommand {
+	case "list_pinned_cids":
+		fs := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		filterType := fs.String("filter-type", "recursive", "")
+		filterName := fs.String("filter-name", "", "")
+		streamOut := fs.Bool("stream", false, "")
+		if err := fs.Parse(subcommandArgs); err != nil || *streamOut {
+			return nil, false
+		}
+		return map[string]interface{}{"filter-type": *filterType, "filter-name": *filterName}, true
+
+	case "dht_find_providers":
+		fs := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		cidStr := fs.String("cid", "", "")
+		numProviders := fs.Int("num-providers", 20, "")
+		if err := fs.Parse(subcommandArgs); err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{"cid": *cidStr, "num-providers": float64(*numProviders)}, true
+
+	case "resolve_ipns":
+		fs := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		ipnsName := fs.String("ipns-name", "", "")
+		nocache := fs.Bool("nocache", true, "")
+		if err := fs.Parse(subcommandArgs); err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{"ipns-name": *ipnsName, "nocache": *nocache}, true
+
+	case "inspect_ipns":
+		fs := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		ipnsName := fs.String("ipns-name", "", "")
+		minSequence := fs.Uint64("min-sequence", 0, "")
+		if err := fs.Parse(subcommandArgs); err != nil {
+			return nil, false
+		}
+		return map[string]interface{}{"ipns-name": *ipnsName, "min-sequence": float64(*minSequence)}, true
+
+	case "pin":
+		fs := flag.NewFlagSet(subcommand, flag.ContinueOnError)
+		if err := fs.Parse(subcommandArgs); err != nil || len(fs.Args()) < 1 {
+			return nil, false
+		}
+		return map[string]interface{}{"cid": fs.Args()[0]}, true
+
+	default:
+		return nil, false
+	}
+}
+
+// tryDaemonSocket connects to a running 'daemon' subcommand over socketPath
+// and, if subcommand is one dispatchDaemon supports, sends it as a
+// daemonRequest and prints the daemonReply. It returns false (having printed
+// no response; a flag parse error is still written to stderr by the flag
+// package) when the socket isn't reachable or the subcommand/flags aren't
+// supported over the socket protocol, so the caller can transparently fall
+// back to connecting to the IPFS API directly. cidBaseArg is this client's
+// own --cid-base flag value (possibly empty); the daemon rejects the request
+// if it doesn't match the base the daemon process itself started with.
+func tryDaemonSocket(socketPath string, subcommand string, subcommandArgs []string, cidBaseArg string) bool {
+	if !daemonClientSubcommands[subcommand] {
+		return false
+	}
+	daemonArgs, ok := daemonClientArgs(subcommand, subcommandArgs)
+	if !ok {
+		return false
+	}
+	conn, err := net.DialTimeout("unix", socketPath, 1*time.Second)
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	req := daemonRequest{ID: "1", Subcommand: subcommand, Args: daemonArgs, CidBase: cidBaseArg}
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		printJSONResponse(false, fmt.Sprintf("Failed to send request to daemon socket '%s': %s", socketPath, err.Error()), nil)
+		return true
+	}
+
+	conn.SetReadDeadline(time.Now().Add(120 * time.Second))
+	var reply daemonReply
+	if err := json.NewDecoder(conn).Decode(&reply); err != nil {
+		printJSONResponse(false, fmt.Sprintf("Failed to read reply from daemon socket '%s': %s", socketPath, err.Error()), nil)
+		return true
+	}
+	printJSONResponse(reply.Success, reply.Error, reply.Data)
+	return true
+}
+
+// stringSliceFlag implements flag.Value for flags that may be repeated, e.g.
+// `--origin <maddr1> --origin <maddr2>`.
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// clusterPinWorkerLimit bounds how many peers are pinned to concurrently, the
+// same "bounded worker pool" shape ipfs-cluster uses for fan-out pin requests.
+const clusterPinWorkerLimit = 8
+
+// pinOnPeer connects to the peer at maddrStr and pins p, reporting the result
+// as a peerPinStatus for pintracker.json.
+func pinOnPeer(ctx context.Context, maddrStr string, p path.Path) peerPinStatus {
+	maddr, err := ma.NewMultiaddr(maddrStr)
+	if err != nil {
+		return peerPinStatus{Status: "error", Error: fmt.Sprintf("invalid multiaddress: %s", err.Error())}
+	}
+	peerAPI, err := rpc.NewApi(maddr)
+	if err != nil {
+		return peerPinStatus{Status: "error", Error: fmt.Sprintf("failed to connect: %s", err.Error())}
+	}
+	if err := peerAPI.Pin().Add(ctx, p); err != nil {
+		return peerPinStatus{Status: "error", Error: err.Error()}
+	}
+	return peerPinStatus{Status: "pinned"}
+}
+
+// fanOutPin pins p on every peer in peers using a bounded worker pool and
+// returns the per-peer status map.
+func fanOutPin(ctx context.Context, peers []string, p path.Path) map[string]peerPinStatus {
+	results := make(map[string]peerPinStatus, len(peers))
+	var mu sync.Mutex
+	sem := make(chan struct{}, clusterPinWorkerLimit)
+	var wg sync.WaitGroup
+	for _, peerAddr := range peers {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(addr string) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			status := pinOnPeer(ctx, addr, p)
+			mu.Lock()
+			results[addr] = status
+			mu.Unlock()
+		}(peerAddr)
+	}
+	wg.Wait()
+	return results
+}
+
+// lsOnPeer connects to the peer at maddrStr and reports whether wantCid is
+// pinned there.
+func lsOnPeer(ctx context.Context, maddrStr string, wantCid cid.Cid) peerPinStatus {
+	maddr, err := ma.NewMultiaddr(maddrStr)
+	if err != nil {
+		return peerPinStatus{Status: "error", Error: fmt.Sprintf("invalid multiaddress: %s", err.Error())}
+	}
+	peerAPI, err := rpc.NewApi(maddr)
+	if err != nil {
+		return peerPinStatus{Status: "error", Error: fmt.Sprintf("failed to connect: %s", err.Error())}
+	}
+	// PinAPI.Ls streams onto a channel the caller allocates rather than
+	// returning one, and only reports its error once that channel is closed.
+	pinsChan := make(chan iface.Pin)
+	lsErrCh := make(chan error, 1)
+	go func() { lsErrCh <- peerAPI.Pin().Ls(ctx, pinsChan) }()
+
+	found := false
+	for entry := range pinsChan {
+		if entry.Path().RootCid() == wantCid {
+			found = true
+		}
+	}
+	if lsErr := <-lsErrCh; lsErr != nil {
+		return peerPinStatus{Status: "error", Error: lsErr.Error()}
+	}
+	if found {
+		return peerPinStatus{Status: "pinned"}
+	}
+	return peerPinStatus{Status: "queued"} // Not found in the peer's pinset yet.
+}
+
+func main() {
+	// rootCtx is cancelled on SIGINT/SIGTERM so a one-shot subcommand
+	// mid-operation (add_file, get_cid_to_file, ...) unwinds via context
+	// cancellation instead of being killed outright by Go's default signal
+	// behavior. Every subcommand's context.WithTimeout below derives from it.
+	rootCtx, rootCancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer rootCancel()
+
+	// --- Global Flags ---
+	globalFlags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
+	apiAddrStr := globalFlags.String("api", "/ip4/127.0.0.1/tcp/5001", "IPFS daemon API multiaddress")
+	autoMigrate := globalFlags.Bool("auto-migrate", false, "Automatically download a compatible Kubo binary and migrate the repo when the version check fails")
+	cidBaseFlag := globalFlags.String("cid-base", "", "Multibase encoding used for CIDs in responses (e.g. base32, base36, base58btc); default is each CID's own base")
+	waitReady := globalFlags.Duration("wait-ready", 0, "Wait up to this long for the IPFS daemon to become reachable before giving up (0 disables waiting, for backward compat)")
+	readyInterval := globalFlags.Duration("ready-interval", 1*time.Second, "Interval between readiness retries when --wait-ready is set")
+	useDaemon := globalFlags.Bool("daemon", false, "Connect to a running 'daemon' subcommand over its Unix socket instead of forking a fresh API connection, falling back transparently if the socket is unreachable")
+	socketPathFlag := globalFlags.String("socket", defaultSocketPath(), "Unix domain socket path used with --daemon")
+
+	args := os.Args[1:]
+
+	// Attempt to parse global flags from the beginning of the arguments.
+	// flag.Parse will stop at the first non-flag argument.
+	err := globalFlags.Parse(args)
+	if err != nil {
+		// For flag.ExitOnError, a fatal flag error would have exited.
+		// If we are here, it might be a less severe issue or it simply stopped.
+		// We can print the error for debugging if necessary, but often globalFlags.Args() will have what we need.
+		// fmt.Fprintln(os.Stderr, "Debug: Global flag parsing encountered an issue (or stopped):", err)
+	}
+
+	// The remaining arguments after global flag parsing are the subcommand and its arguments.
+	nonFlagArgs := globalFlags.Args()
+	var subcommand string
+	var subcommandArgs []string
+
+	if len(nonFlagArgs) > 0 {
+		subcommand = nonFlagArgs[0]
+		subcommandArgs = nonFlagArgs[1:]
+	} else {
+		// No subcommand found after global flags (or no args at all other than flags)
+		printJSONResponse(false, "Subcommand required after global flags (e.g., version, pin, add_file)", nil)
+		return
+	}
+
+	if *cidBaseFlag != "" {
+		if _, encErr := mbase.EncoderByName(*cidBaseFlag); encErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid --cid-base '%s': %s", *cidBaseFlag, encErr.Error()), nil)
+			return
+		}
+		cidBase = *cidBaseFlag
+	}
+
+	// --- Daemon Socket Fast Path ---
+	// With --daemon set, route supported subcommands through the 'daemon'
+	// subcommand's Unix socket instead of paying the fork+exec and API
+	// reconnect cost below. Falls back to the normal path below transparently
+	// if the socket isn't there (daemon subcommand not running) or the
+	// subcommand/flags aren't supported over the socket protocol.
+	if *useDaemon && tryDaemonSocket(*socketPathFlag, subcommand, subcommandArgs, *cidBaseFlag) {
+		return
+	}
+
+	// --- IPFS Version Check ---
+	// Perform this check early, before trying to connect or use specific subcommands
+	// unless the subcommand is 'version' itself for the wrapper.
+	nonFlagArgsForVersionCheck := globalFlags.Args()
+	performVersionCheck := true
+	if len(nonFlagArgsForVersionCheck) > 0 {
+		switch nonFlagArgsForVersionCheck[0] {
+		case "version", "install_ipfs", "cid_convert":
+			// Don't run the IPFS version check for commands that don't need a running daemon.
+			performVersionCheck = false
+		default:
+			if strings.HasPrefix(nonFlagArgsForVersionCheck[0], "cluster_") {
+				// cluster_* subcommands connect directly to each configured peer
+				// rather than the local daemon, so they don't need it either.
+				performVersionCheck = false
+			}
+		}
+	}
+
+	if performVersionCheck {
+		err = checkIPFSVersion()
+		if err != nil {
+			if !*autoMigrate {
+				printJSONResponse(false, fmt.Sprintf("IPFS Version Check Failed: %s", err.Error()), nil)
+				return // Exit if version check fails and auto-migration was not requested
+			}
+
+			migrateCtx, migrateCancel := context.WithTimeout(rootCtx, 10*time.Minute)
+			defer migrateCancel()
+
+			onProgress := func(msg string) {
+				fmt.Println(string(mustJSON(CommandResponse{Success: true, Data: map[string]string{"event": "migrate-progress", "message": msg}})))
+			}
+
+			latest, fetchErr := fetchLatestKuboVersion(migrateCtx, RequiredIPFSVersion)
+			if fetchErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Auto-migrate failed to determine a target Kubo version: %s", fetchErr.Error()), nil)
+				return
+			}
+			ipfsBinPath, dlErr := downloadAndVerifyKubo(migrateCtx, latest, onProgress)
+			if dlErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Auto-migrate failed to install Kubo %s: %s", latest, dlErr.Error()), nil)
+				return
+			}
+			if migErr := runRepoMigration(migrateCtx, *apiAddrStr, ipfsBinPath, onProgress); migErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Auto-migrate failed to migrate the repo: %s", migErr.Error()), nil)
+				return
+			}
+			// runRepoMigration restarts the daemon without waiting for it to
+			// come back up; force the readiness gate below to actually retry
+			// even if the caller never passed --wait-ready themselves.
+			if *waitReady < minPostMigrationWaitReady {
+				*waitReady = minPostMigrationWaitReady
+			}
+			if prependErr := os.Setenv("PATH", filepath.Dir(ipfsBinPath)+string(os.PathListSeparator)+os.Getenv("PATH")); prependErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Auto-migrate succeeded but failed to update PATH: %s", prependErr.Error()), nil)
+				return
+			}
+			onProgress(fmt.Sprintf("using kubo %s at %s for remaining exec.Command calls", latest, ipfsBinPath))
+		}
+	}
+
+	// --- IPFS Node Connection ---
+	// install_ipfs manages the ipfs binary itself, so it must not require a
+	// running daemon to already be reachable.
+	var node *rpc.HttpApi
+	// Populated by the ID check below; declared here (rather than inside the
+	// connection-guard block) so daemon_info can still read it afterwards.
+	var idOutput IDResponse
+	if subcommand != "install_ipfs" && subcommand != "cid_convert" && !strings.HasPrefix(subcommand, "cluster_") {
+		apiMaddr, apiErr := ma.NewMultiaddr(*apiAddrStr)
+		if apiErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid API multiaddress '%s': %s", *apiAddrStr, apiErr.Error()), nil)
+			return
+		}
+
+		// Context for API calls (not for NewApi itself if it doesn't take it)
+		// connectCtx, connectCancel := context.WithTimeout(rootCtx, 10*time.Second)
+		// defer connectCancel()
+
+		node, err = rpc.NewApi(apiMaddr) // Removed context from NewApi call
+		if err != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to connect to IPFS node at %s: %s", *apiAddrStr, err.Error()), nil)
+			return
+		}
+
+		// A simple ID check to confirm connection. When --wait-ready is set,
+		// retry on a bounded interval instead of failing on the first
+		// transient error, so a daemon that is still starting up doesn't get
+		// reported to the Python client as a real failure — the same
+		// readiness-gate pattern ipfs-cluster uses before it starts
+		// publishing metrics or connecting peers.
+		readyDeadline := time.Now().Add(*waitReady)
+		var idErr error
+		for {
+			idCtx, idCancel := context.WithTimeout(rootCtx, 10*time.Second)
+			idErr = node.Request("id").Exec(idCtx, &idOutput) // Use generic request for /id
+			idCancel()
+			if idErr == nil || *waitReady <= 0 || time.Now().After(readyDeadline) {
+				break
+			}
+			time.Sleep(*readyInterval)
+		}
+		if idErr != nil {
+			if *waitReady > 0 {
+				printJSONResponse(false, fmt.Sprintf("ipfs daemon not ready after %s", waitReady.String()), map[string]string{"LastError": idErr.Error()})
+			} else {
+				printJSONResponse(false, fmt.Sprintf("Failed to get ID from IPFS node at %s (connection check failed): %s", *apiAddrStr, idErr.Error()), nil)
+			}
+			return
+		}
+		// If successful, idOutput is populated. We don't need to print it here, just check error.
+	}
+
+	// --- Subcommand Handling ---
+	switch subcommand {
+	case "version":
+		printJSONResponse(true, "", map[string]string{"version": WrapperVersion})
+
+	case "daemon":
+		// Starts a persistent process listening on a Unix domain socket so
+		// callers that issue many requests in a row (list pins, find
+		// providers, resolve many IPNS names) don't pay a fork+exec per
+		// call. See dispatchDaemon for the protocol and handler coverage.
+		daemonCmd := flag.NewFlagSet("daemon", flag.ExitOnError)
+		socketPath := daemonCmd.String("socket", defaultSocketPath(), "Unix domain socket path to listen on")
+		if err := daemonCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'daemon' subcommand: %s", err.Error()), nil)
+			return
+		}
+
+		// Remove a stale socket left behind by a previous, uncleanly killed
+		// daemon; net.Listen fails with "address already in use" otherwise.
+		if _, statErr := os.Stat(*socketPath); statErr == nil {
+			os.Remove(*socketPath)
+		}
+
+		listener, listenErr := net.Listen("unix", *socketPath)
+		if listenErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to listen on socket '%s': %s", *socketPath, listenErr.Error()), nil)
+			return
+		}
+
+		sigCh := make(chan os.Signal, 1)
+		signal.Notify(sigCh, os.Interrupt, syscall.SIGTERM)
+		go func() {
+			<-sigCh
+			listener.Close()
+			os.Remove(*socketPath)
+			os.Exit(0)
+		}()
+
+		fmt.Fprintf(os.Stderr, "scipfs daemon listening on %s\n", *socketPath)
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				// Expected once the signal handler above closes the listener.
+				return
+			}
+			go handleDaemonConn(node, *apiAddrStr, conn)
+		}
+
+	case "install_ipfs":
+		installCmd := flag.NewFlagSet("install_ipfs", flag.ExitOnError)
+		wantVersion := installCmd.String("version", "", "Kubo version to install (default: latest version satisfying RequiredIPFSVersion)")
+		if err := installCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'install_ipfs' subcommand: %s", err.Error()), nil)
+			return
+		}
+
+		installCtx, installCancel := context.WithTimeout(rootCtx, 10*time.Minute)
+		defer installCancel()
+
+		target := *wantVersion
+		if target == "" {
+			latest, fetchErr := fetchLatestKuboVersion(installCtx, RequiredIPFSVersion)
+			if fetchErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Failed to determine latest kubo version: %s", fetchErr.Error()), nil)
+				return
+			}
+			target = latest
+		}
+
+		onProgress := func(msg string) {
+			fmt.Println(string(mustJSON(CommandResponse{Success: true, Data: map[string]string{"event": "install-progress", "message": msg}})))
+		}
+		binPath, dlErr := downloadAndVerifyKubo(installCtx, target, onProgress)
+		if dlErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to install kubo %s: %s", target, dlErr.Error()), nil)
+			return
+		}
+		printJSONResponse(true, "", map[string]string{"version": target, "path": binPath})
+	case "cid_convert":
+		convertCmd := flag.NewFlagSet("cid_convert", flag.ExitOnError)
+		cidStr := convertCmd.String("cid", "", "CID to re-encode in every supported multibase")
+		if err := convertCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cid_convert' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if *cidStr == "" {
+			printJSONResponse(false, "Argument --cid is required", nil)
+			return
+		}
+		decoded, decodeErr := cid.Decode(*cidStr)
+		if decodeErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", *cidStr, decodeErr.Error()), nil)
+			return
+		}
+		v1 := decoded
+		if v1.Version() == 0 {
+			v1 = cid.NewCidV1(decoded.Type(), decoded.Hash())
+		}
+
+		encoded := make(map[string]string, len(mbase.EncodingToStr))
+		for enc, name := range mbase.EncodingToStr {
+			if s, encErr := v1.StringOfBase(enc); encErr == nil {
+				encoded[name] = s
+			}
+		}
+		if decoded.Version() == 0 {
+			encoded["base58btc (CIDv0)"] = decoded.String()
+		}
+		printJSONResponse(true, "", map[string]interface{}{"cid": decoded.String(), "encodings": encoded})
+
+	case "cluster_peer_add":
+		peerAddCmd := flag.NewFlagSet("cluster_peer_add", flag.ExitOnError)
+		if err := peerAddCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_peer_add' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if len(peerAddCmd.Args()) < 1 {
+			printJSONResponse(false, "A peer multiaddress argument is required for cluster_peer_add", nil)
+			return
+		}
+		newPeer := peerAddCmd.Args()[0]
+		if _, maErr := ma.NewMultiaddr(newPeer); maErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid peer multiaddress '%s': %s", newPeer, maErr.Error()), nil)
+			return
+		}
+
+		peers, loadErr := loadClusterPeers()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		for _, existing := range peers {
+			if existing == newPeer {
+				printJSONResponse(true, "", map[string]interface{}{"peers": peers, "message": "peer already present"})
+				return
+			}
+		}
+		peers = append(peers, newPeer)
+		if saveErr := saveClusterPeers(peers); saveErr != nil {
+			printJSONResponse(false, saveErr.Error(), nil)
+			return
+		}
+		printJSONResponse(true, "", map[string]interface{}{"peers": peers})
+
+	case "cluster_peer_rm":
+		peerRmCmd := flag.NewFlagSet("cluster_peer_rm", flag.ExitOnError)
+		if err := peerRmCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_peer_rm' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if len(peerRmCmd.Args()) < 1 {
+			printJSONResponse(false, "A peer multiaddress argument is required for cluster_peer_rm", nil)
+			return
+		}
+		target := peerRmCmd.Args()[0]
+
+		peers, loadErr := loadClusterPeers()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		remaining := peers[:0]
+		for _, existing := range peers {
+			if existing != target {
+				remaining = append(remaining, existing)
+			}
+		}
+		if saveErr := saveClusterPeers(remaining); saveErr != nil {
+			printJSONResponse(false, saveErr.Error(), nil)
+			return
+		}
+		printJSONResponse(true, "", map[string]interface{}{"peers": remaining})
+
+	case "cluster_peers":
+		peersCmd := flag.NewFlagSet("cluster_peers", flag.ExitOnError)
+		if err := peersCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_peers' subcommand: %s", err.Error()), nil)
+			return
+		}
+		peers, loadErr := loadClusterPeers()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		printJSONResponse(true, "", map[string]interface{}{"peers": peers})
+
+	case "cluster_pin":
+		clusterPinCmd := flag.NewFlagSet("cluster_pin", flag.ExitOnError)
+		cidStr := clusterPinCmd.String("cid", "", "CID to pin across the cluster")
+		replMin := clusterPinCmd.Int("replication-min", 0, "Minimum number of peers that must pin successfully (0 = no minimum enforced)")
+		replMax := clusterPinCmd.Int("replication-max", 0, "Maximum number of peers to target (0 = all configured/allocated peers)")
+		name := clusterPinCmd.String("name", "", "Friendly name echoed back in the response; it is not stored in pintracker.json or sent to the peers")
+		allocationsStr := clusterPinCmd.String("allocations", "", "Comma-separated peer multiaddresses to pin to (default: all configured cluster peers)")
+
+		if err := clusterPinCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_pin' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if *cidStr == "" {
+			printJSONResponse(false, "Argument --cid is required", nil)
+			return
+		}
+		if _, decodeErr := cid.Decode(*cidStr); decodeErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", *cidStr, decodeErr.Error()), nil)
+			return
+		}
+		p, pathErr := path.NewPath("/ipfs/" + *cidStr)
+		if pathErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for '%s': %s", *cidStr, pathErr.Error()), nil)
+			return
+		}
+
+		var allocations []string
+		if *allocationsStr != "" {
+			allocations = strings.Split(*allocationsStr, ",")
+		} else {
+			configured, loadErr := loadClusterPeers()
+			if loadErr != nil {
+				printJSONResponse(false, loadErr.Error(), nil)
+				return
+			}
+			allocations = configured
+		}
+		if len(allocations) == 0 {
+			printJSONResponse(false, "No cluster peers configured; use cluster_peer_add or --allocations", nil)
+			return
+		}
+		if *replMax > 0 && *replMax < len(allocations) {
+			allocations = allocations[:*replMax]
+		}
 
-	isOlder, err := compareVersions(installedVersion, RequiredIPFSVersion)
-	if err != nil {
-		return fmt.Errorf("failed to compare IPFS versions (installed: '%s', required: '%s'): %w", installedVersion, RequiredIPFSVersion, err)
-	}
+		ctxClusterPin, cancelClusterPin := context.WithTimeout(rootCtx, 120*time.Second)
+		defer cancelClusterPin()
 
-	if isOlder {
-		return fmt.Errorf("installed IPFS version '%s' is older than required version '%s'. Please upgrade your IPFS (Kubo) daemon/CLI to %s or newer", installedVersion, RequiredIPFSVersion, RequiredIPFSVersion)
-	}
-	// fmt.Fprintf(os.Stderr, "Debug: IPFS version check passed. Installed: %s, Required: %s\n", installedVersion, RequiredIPFSVersion) // Optional debug
-	return nil
-}
+		statuses := fanOutPin(ctxClusterPin, allocations, p)
 
-func main() {
-	// --- Global Flags ---
-	globalFlags := flag.NewFlagSet(os.Args[0], flag.ExitOnError)
-	apiAddrStr := globalFlags.String("api", "/ip4/127.0.0.1/tcp/5001", "IPFS daemon API multiaddress")
+		succeeded := 0
+		for _, s := range statuses {
+			if s.Status == "pinned" {
+				succeeded++
+			}
+		}
 
-	args := os.Args[1:]
+		tracker, loadErr := loadPinTracker()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		tracker[*cidStr] = statuses
+		if saveErr := savePinTracker(tracker); saveErr != nil {
+			printJSONResponse(false, saveErr.Error(), nil)
+			return
+		}
 
-	// Attempt to parse global flags from the beginning of the arguments.
-	// flag.Parse will stop at the first non-flag argument.
-	err := globalFlags.Parse(args)
-	if err != nil {
-		// For flag.ExitOnError, a fatal flag error would have exited.
-		// If we are here, it might be a less severe issue or it simply stopped.
-		// We can print the error for debugging if necessary, but often globalFlags.Args() will have what we need.
-		// fmt.Fprintln(os.Stderr, "Debug: Global flag parsing encountered an issue (or stopped):", err)
-	}
+		if *replMin > 0 && succeeded < *replMin {
+			printJSONResponse(false, fmt.Sprintf("Only %d/%d peers pinned '%s', below --replication-min %d", succeeded, len(allocations), *cidStr, *replMin), map[string]interface{}{"cid": *cidStr, "name": *name, "status": statuses})
+			return
+		}
 
-	// The remaining arguments after global flag parsing are the subcommand and its arguments.
-	nonFlagArgs := globalFlags.Args()
-	var subcommand string
-	var subcommandArgs []string
+		printJSONResponse(true, "", map[string]interface{}{"cid": *cidStr, "name": *name, "status": statuses})
 
-	if len(nonFlagArgs) > 0 {
-		subcommand = nonFlagArgs[0]
-		subcommandArgs = nonFlagArgs[1:]
-	} else {
-		// No subcommand found after global flags (or no args at all other than flags)
-		printJSONResponse(false, "Subcommand required after global flags (e.g., version, pin, add_file)", nil)
-		return
-	}
+	case "cluster_status":
+		statusCmd := flag.NewFlagSet("cluster_status", flag.ExitOnError)
+		cidStr := statusCmd.String("cid", "", "CID to report per-peer cluster status for")
+		if err := statusCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_status' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if *cidStr == "" {
+			printJSONResponse(false, "Argument --cid is required", nil)
+			return
+		}
+		wantCid, decodeErr := cid.Decode(*cidStr)
+		if decodeErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", *cidStr, decodeErr.Error()), nil)
+			return
+		}
 
-	// --- IPFS Version Check ---
-	// Perform this check early, before trying to connect or use specific subcommands
-	// unless the subcommand is 'version' itself for the wrapper.
-	nonFlagArgsForVersionCheck := globalFlags.Args()
-	performVersionCheck := true
-	if len(nonFlagArgsForVersionCheck) > 0 && nonFlagArgsForVersionCheck[0] == "version" {
-		// Don't run IPFS version check if the command IS to get the wrapper's version
-		performVersionCheck = false
-	}
+		peers, loadErr := loadClusterPeers()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
 
-	if performVersionCheck {
-		err = checkIPFSVersion()
-		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("IPFS Version Check Failed: %s", err.Error()), nil)
-			return // Exit if version check fails
+		ctxStatus, cancelStatus := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelStatus()
+
+		statuses := make(map[string]peerPinStatus, len(peers))
+		var mu sync.Mutex
+		sem := make(chan struct{}, clusterPinWorkerLimit)
+		var wg sync.WaitGroup
+		for _, peerAddr := range peers {
+			wg.Add(1)
+			sem <- struct{}{}
+			go func(addr string) {
+				defer wg.Done()
+				defer func() { <-sem }()
+				s := lsOnPeer(ctxStatus, addr, wantCid)
+				mu.Lock()
+				statuses[addr] = s
+				mu.Unlock()
+			}(peerAddr)
 		}
-	}
+		wg.Wait()
 
-	// --- IPFS Node Connection ---
-	var node *rpc.HttpApi
+		tracker, loadErr := loadPinTracker()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		tracker[*cidStr] = statuses
+		if saveErr := savePinTracker(tracker); saveErr != nil {
+			printJSONResponse(false, saveErr.Error(), nil)
+			return
+		}
 
-	apiMaddr, err := ma.NewMultiaddr(*apiAddrStr)
-	if err != nil {
-		printJSONResponse(false, fmt.Sprintf("Invalid API multiaddress '%s': %s", *apiAddrStr, err.Error()), nil)
-		return
-	}
+		printJSONResponse(true, "", map[string]interface{}{"cid": *cidStr, "status": statuses})
 
-	// Context for API calls (not for NewApi itself if it doesn't take it)
-	// connectCtx, connectCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	// defer connectCancel()
+	case "cluster_recover":
+		recoverCmd := flag.NewFlagSet("cluster_recover", flag.ExitOnError)
+		cidStr := recoverCmd.String("cid", "", "CID to re-attempt pinning on peers reporting error")
+		if err := recoverCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'cluster_recover' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if *cidStr == "" {
+			printJSONResponse(false, "Argument --cid is required", nil)
+			return
+		}
+		p, pathErr := path.NewPath("/ipfs/" + *cidStr)
+		if pathErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for '%s': %s", *cidStr, pathErr.Error()), nil)
+			return
+		}
 
-	node, err = rpc.NewApi(apiMaddr) // Removed context from NewApi call
-	if err != nil {
-		printJSONResponse(false, fmt.Sprintf("Failed to connect to IPFS node at %s: %s", *apiAddrStr, err.Error()), nil)
-		return
-	}
+		tracker, loadErr := loadPinTracker()
+		if loadErr != nil {
+			printJSONResponse(false, loadErr.Error(), nil)
+			return
+		}
+		existing, known := tracker[*cidStr]
+		if !known {
+			printJSONResponse(false, fmt.Sprintf("No tracked status for CID '%s'; run cluster_pin or cluster_status first", *cidStr), nil)
+			return
+		}
 
-	// A simple ID check to confirm connection
-	// We'll use the generic Request for the ID command
-	idCtx, idCancel := context.WithTimeout(context.Background(), 10*time.Second)
-	defer idCancel()
+		var errored []string
+		for peerAddr, status := range existing {
+			if status.Status == "error" {
+				errored = append(errored, peerAddr)
+			}
+		}
+		if len(errored) == 0 {
+			printJSONResponse(true, "", map[string]interface{}{"cid": *cidStr, "status": existing, "message": "no peers reporting error"})
+			return
+		}
 
-	var idOutput IDResponse // Use the struct for unmarshalling
-	err = node.Request("id").Exec(idCtx, &idOutput) // Use generic request for /id
-	if err != nil {
-		printJSONResponse(false, fmt.Sprintf("Failed to get ID from IPFS node at %s (connection check failed): %s", *apiAddrStr, err.Error()), nil)
-		return
-	}
-	// If successful, idOutput is populated. We don't need to print it here, just check error.
+		ctxRecover, cancelRecover := context.WithTimeout(rootCtx, 120*time.Second)
+		defer cancelRecover()
+
+		retried := fanOutPin(ctxRecover, errored, p)
+		for peerAddr, status := range retried {
+			existing[peerAddr] = status
+		}
+		tracker[*cidStr] = existing
+		if saveErr := savePinTracker(tracker); saveErr != nil {
+			printJSONResponse(false, saveErr.Error(), nil)
+			return
+		}
+
+		printJSONResponse(true, "", map[string]interface{}{"cid": *cidStr, "status": existing})
 
-	// --- Subcommand Handling ---
-	switch subcommand {
-	case "version":
-		printJSONResponse(true, "", map[string]string{"version": WrapperVersion})
 	case "daemon_info": // New subcommand to get daemon info (ID, Version etc.)
 		// The ID was already fetched during the connection check. We can reuse idOutput.
 		// If we wanted to fetch fresh, we'd call node.Request("id").Exec(ctx, &idOutput) again.
@@ -248,38 +1920,109 @@ func main() {
 		}
 		cidStr := argsForPin[0]
 
-		// First, validate the CID string itself to ensure it's a well-formed CID
-		_, cidErr := cid.Decode(cidStr)
-		if cidErr != nil {
-			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", cidStr, cidErr.Error()), nil)
+		ctxPin, cancelPin := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelPin()
+
+		data, pinErr := pinCID(ctxPin, node, cidStr)
+		if pinErr != nil {
+			printJSONResponse(false, pinErr.Error(), nil)
+			return
+		}
+		printJSONResponse(true, "", data)
+
+	case "pin_with_origins":
+		// Mirrors ipfs-cluster's ipfshttp connector "pin.Origins" feature:
+		// swarm-connect to known holders of a CID before pinning it, which
+		// dramatically speeds up the fetch when the caller already knows who
+		// has the content (e.g. right after publish_ipns + dht_find_providers).
+		pinOriginsCmd := flag.NewFlagSet("pin_with_origins", flag.ExitOnError)
+		pinCidStr := pinOriginsCmd.String("cid", "", "CID to pin")
+		var origins stringSliceFlag
+		pinOriginsCmd.Var(&origins, "origin", "Multiaddr of a peer to connect to before pinning (repeatable)")
+		discover := pinOriginsCmd.Bool("discover", false, "Also discover providers via the DHT and connect to them")
+		maxOrigins := pinOriginsCmd.Int("max-origins", 10, "Maximum number of discovered providers to connect to")
+
+		err := pinOriginsCmd.Parse(subcommandArgs)
+		if err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'pin_with_origins' subcommand: %s", err.Error()), nil)
 			return
 		}
 
-		// Construct the full IPFS path string
-		ipfsPathStr := "/ipfs/" + cidStr
+		if *pinCidStr == "" {
+			printJSONResponse(false, "Argument --cid is required for pin_with_origins command", nil)
+			return
+		}
+		decodedCid, cidErr := cid.Decode(*pinCidStr)
+		if cidErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", *pinCidStr, cidErr.Error()), nil)
+			return
+		}
 
-		// Now create the path object using the full path string
-		p, pathErr := path.NewPath(ipfsPathStr)
-		if pathErr != nil {
-			// This error would typically indicate issues with the path string itself, even if the CID part was valid
-			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for '%s': %s", ipfsPathStr, pathErr.Error()), nil)
+		apiMaddr, apiErr := ma.NewMultiaddr(*apiAddrStr)
+		if apiErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid API multiaddress '%s': %s", *apiAddrStr, apiErr.Error()), nil)
+			return
+		}
+		kuboClient, kuboErr := newKuboHTTPClient(apiMaddr, 90*time.Second)
+		if kuboErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to build IPFS API client: %s", kuboErr.Error()), nil)
 			return
 		}
 
-		ctxPin, cancelPin := context.WithTimeout(context.Background(), 60*time.Second)
-		defer cancelPin()
+		ctxOrigins, cancelOrigins := context.WithTimeout(rootCtx, 90*time.Second)
+		defer cancelOrigins()
 
-		err = node.Pin().Add(ctxPin, p)
-		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("Failed to pin IPFS path '%s': %s", ipfsPathStr, err.Error()), nil)
+		originAddrs := []string(origins)
+		if *discover {
+			providers, findErr := kuboClient.RoutingFindProvs(ctxOrigins, decodedCid, *maxOrigins)
+			if findErr != nil {
+				fmt.Fprintf(os.Stderr, "Warning: provider discovery for '%s' failed: %s\n", *pinCidStr, findErr.Error())
+			}
+			for i, peerID := range providers {
+				if i >= *maxOrigins {
+					break
+				}
+				originAddrs = append(originAddrs, "/p2p/"+peerID)
+			}
+		}
+
+		var connectedOrigins, failedOrigins []string
+		for _, addr := range originAddrs {
+			if connErr := kuboClient.SwarmConnect(ctxOrigins, addr); connErr != nil {
+				// A failed origin connect must not abort the pin; the content
+				// may still be reachable through other peers or the DHT.
+				fmt.Fprintf(os.Stderr, "Warning: failed to connect to origin '%s': %s\n", addr, connErr.Error())
+				failedOrigins = append(failedOrigins, addr)
+				continue
+			}
+			connectedOrigins = append(connectedOrigins, addr)
+		}
+		if connectedOrigins == nil {
+			connectedOrigins = []string{}
+		}
+		if failedOrigins == nil {
+			failedOrigins = []string{}
+		}
+
+		if pinErr := kuboClient.PinAdd(ctxOrigins, decodedCid); pinErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to pin CID '%s': %s", *pinCidStr, pinErr.Error()), nil)
 			return
 		}
-		printJSONResponse(true, "", map[string]string{"cid": cidStr, "path": ipfsPathStr, "status": "pinned"})
+
+		printJSONResponse(true, "", map[string]interface{}{
+			"CID":              encodeCid(decodedCid),
+			"ConnectedOrigins": connectedOrigins,
+			"FailedOrigins":    failedOrigins,
+		})
 
 	case "add_file":
 		addFileCmd := flag.NewFlagSet("add_file", flag.ExitOnError)
 		filePath := addFileCmd.String("file", "", "Path to the file to add")
-		// Potentially add other flags like --pin, --raw-leaves etc. later if needed.
+		progress := addFileCmd.Bool("progress", false, "Emit NDJSON progress events on stdout instead of a single CommandResponse")
+		chunker := addFileCmd.String("chunker", "size-262144", "Chunking algorithm, e.g. size-262144 or rabin-min-avg-max")
+		rawLeaves := addFileCmd.Bool("raw-leaves", false, "Use raw blocks for leaf nodes (no dag-pb wrapper)")
+		cidVersion := addFileCmd.Int("cid-version", 0, "CID version for the resulting DAG (0 or 1)")
+		hashFun := addFileCmd.String("hash", "sha2-256", "Hash function to use, e.g. sha2-256, blake3")
 
 		err := addFileCmd.Parse(subcommandArgs)
 		if err != nil {
@@ -308,36 +2051,88 @@ func main() {
 			return
 		}
 
-		// Create a files.Node for the IPFS API.
-		// files.NewSerialFile is suitable for adding a single file from a path.
-		fnode, err := files.NewSerialFile(*filePath, false, fileInfo)
-		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("Error creating file node for '%s': %s", *filePath, err.Error()), nil)
+		hashCode, hashErr := mhashCodeForName(*hashFun)
+		if hashErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid --hash value: %s", hashErr.Error()), nil)
 			return
 		}
-		// defer fnode.Close() // files.Node from boxo/files might not need explicit Close like go-ipfs-files, or it's handled differently.
-		// Check documentation if issues arise. For SerialFile, usually data is read at creation or first access.
 
-		ctxAdd, cancelAdd := context.WithTimeout(context.Background(), 120*time.Second) // 2 minute timeout for add
+		addOpts := []options.UnixfsAddOption{
+			options.Unixfs.Chunker(*chunker),
+			options.Unixfs.RawLeaves(*rawLeaves),
+			options.Unixfs.CidVersion(*cidVersion),
+			options.Unixfs.Hash(hashCode),
+		}
+
+		ctxAdd, cancelAdd := context.WithTimeout(rootCtx, 120*time.Second) // 2 minute timeout for add
 		defer cancelAdd()
 
-		// Add the file via Unixfs API
-		// We are not using any options.UnixfsAddOption for now (e.g. Pinning, RawLeaves).
-		// Pinning will be handled by a separate call to the "pin" subcommand from the Python client if needed.
-		// The return type of node.Unixfs().Add is path.ImmutablePath from "github.com/ipfs/boxo/path"
-		addedPath, err := node.Unixfs().Add(ctxAdd, fnode)
-		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("Failed to add file '%s' to IPFS: %s", *filePath, err.Error()), nil)
+		if !*progress {
+			// Create a files.Node for the IPFS API.
+			// files.NewSerialFile is suitable for adding a single file from a path.
+			fnode, fileErr := files.NewSerialFile(*filePath, false, fileInfo)
+			if fileErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Error creating file node for '%s': %s", *filePath, fileErr.Error()), nil)
+				return
+			}
+
+			// The return type of node.Unixfs().Add is path.ImmutablePath from "github.com/ipfs/boxo/path"
+			addedPath, addErr := node.Unixfs().Add(ctxAdd, fnode, addOpts...)
+			if addErr != nil {
+				printJSONResponse(false, fmt.Sprintf("Failed to add file '%s' to IPFS: %s", *filePath, addErr.Error()), nil)
+				return
+			}
+
+			cidValue := addedPath.RootCid()
+			if !cidValue.Defined() {
+				printJSONResponse(false, fmt.Sprintf("Failed to get a defined CID for file '%s'", *filePath), nil)
+				return
+			}
+			printJSONResponse(true, "", map[string]string{"cid": encodeCid(cidValue)})
 			return
 		}
 
-		// Use RootCid() method from github.com/ipfs/boxo/path.ImmutablePath
-		cidValue := addedPath.RootCid() // CORRECTED to RootCid()
+		// --progress: switch to newline-delimited JSON events on stdout so large
+		// adds are observable and the caller isn't left staring at a silent
+		// process for up to two minutes.
+		osFile, openErr := os.Open(*filePath)
+		if openErr != nil {
+			fmt.Fprintln(os.Stderr, string(mustJSON(map[string]string{"event": "error", "message": openErr.Error()})))
+			os.Exit(1)
+		}
+		defer osFile.Close()
+
+		fmt.Println(string(mustJSON(map[string]interface{}{"event": "start", "size": fileInfo.Size()})))
+
+		counted := &countingReader{r: osFile}
+		progressDone := make(chan struct{})
+		go func() {
+			ticker := time.NewTicker(250 * time.Millisecond) // ~4x/second
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ticker.C:
+					fmt.Println(string(mustJSON(map[string]interface{}{"event": "progress", "bytes": counted.BytesRead()})))
+				case <-progressDone:
+					return
+				}
+			}
+		}()
+
+		fnode := files.NewReaderFile(counted)
+		addedPath, addErr := node.Unixfs().Add(ctxAdd, fnode, addOpts...)
+		close(progressDone)
+		if addErr != nil {
+			fmt.Fprintln(os.Stderr, string(mustJSON(map[string]string{"event": "error", "message": addErr.Error()})))
+			os.Exit(1)
+		}
+
+		cidValue := addedPath.RootCid()
 		if !cidValue.Defined() {
-			printJSONResponse(false, fmt.Sprintf("Failed to get a defined CID for file '%s'", *filePath), nil)
-			return
+			fmt.Fprintln(os.Stderr, string(mustJSON(map[string]string{"event": "error", "message": fmt.Sprintf("failed to get a defined CID for file '%s'", *filePath)})))
+			os.Exit(1)
 		}
-		printJSONResponse(true, "", map[string]string{"cid": cidValue.String()})
+		fmt.Println(string(mustJSON(map[string]interface{}{"event": "done", "cid": encodeCid(cidValue), "size": fileInfo.Size()})))
 
 	case "get_cid_to_file":
 		getCidToFileCmd := flag.NewFlagSet("get_cid_to_file", flag.ExitOnError)
@@ -365,7 +2160,31 @@ func main() {
 			printJSONResponse(false, fmt.Sprintf("Invalid CID format for '%s': %s", *cidStr, err.Error()), nil)
 			return
 		}
-		
+
+		ipfsPath, pathErr := path.NewPath("/ipfs/" + *cidStr)
+		if pathErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for CID '%s': %s", *cidStr, pathErr.Error()), nil)
+			return
+		}
+
+		ctxGet, cancelGet := context.WithTimeout(rootCtx, 120*time.Second)
+		defer cancelGet()
+
+		// Fetch via the already-connected node handle so context cancellation and
+		// the --api flag apply the same way they do for every other subcommand.
+		fnode, getErr := node.Unixfs().Get(ctxGet, ipfsPath)
+		if getErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to get CID '%s' from IPFS: %s", *cidStr, getErr.Error()), nil)
+			return
+		}
+		defer fnode.Close()
+
+		fileReader, ok := fnode.(files.File)
+		if !ok {
+			printJSONResponse(false, fmt.Sprintf("CID '%s' does not resolve to a file (it may be a directory)", *cidStr), nil)
+			return
+		}
+
 		// Create/truncate the output file
 		outFile, err := os.Create(*outputPath)
 		if err != nil {
@@ -374,29 +2193,13 @@ func main() {
 		}
 		defer outFile.Close()
 
-		// Prepare the 'ipfs cat' command
-		// We are not using the Kubo client library here for 'cat' to directly stream to file easily using os/exec.
-		// The Kubo client's 'Cat' method returns an io.ReadCloser, which could also be used with io.Copy.
-		// However, for this migration, using 'ipfs cat' via os/exec is closer to the other planned CLI wrappers.
-		cmd := exec.Command("ipfs", "cat", *cidStr)
-		cmd.Stdout = outFile // Redirect stdout of 'ipfs cat' to the output file
-		
-		// Capture stderr to report IPFS command errors
-		var stderr bytes.Buffer
-		cmd.Stderr = &stderr
-
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs cat %s': %s", *cidStr, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		if _, err := io.Copy(outFile, fileReader); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error writing fetched content for CID '%s' to '%s': %s", *cidStr, *outputPath, err.Error()), nil)
 			// Attempt to remove partially written file on error
 			os.Remove(*outputPath)
 			return
 		}
-		
+
 		printJSONResponse(true, "", map[string]string{"message": fmt.Sprintf("File downloaded successfully to %s", *outputPath), "cid": *cidStr, "output_path": *outputPath})
 
 	case "get_json_cid":
@@ -421,31 +2224,40 @@ func main() {
 			return
 		}
 
-		// Use Kubo client library to get the content, as it handles various character encodings better than direct CLI piping for JSON.
-		// However, the original plan was to use CLI for all. Sticking to CLI for consistency during this phase.
-		// If issues arise with complex JSON, this can be switched to node.Cat().
+		// Use the already-connected node handle instead of shelling out to 'ipfs cat',
+		// so this honors the --api flag and context cancellation like every other subcommand.
+		jsonPath, pathErr := path.NewPath("/ipfs/" + decodedCid.String())
+		if pathErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for CID '%s': %s", decodedCid.String(), pathErr.Error()), nil)
+			return
+		}
 
-		cmd := exec.Command("ipfs", "cat", decodedCid.String()) // Use decodedCid.String() for canonical representation
-		
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+		ctxGetJSON, cancelGetJSON := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelGetJSON()
 
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs cat %s': %s", decodedCid.String(), err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		fnode, getErr := node.Unixfs().Get(ctxGetJSON, jsonPath)
+		if getErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to get CID '%s' from IPFS: %s", decodedCid.String(), getErr.Error()), nil)
+			return
+		}
+		defer fnode.Close()
+
+		fileReader, ok := fnode.(files.File)
+		if !ok {
+			printJSONResponse(false, fmt.Sprintf("CID '%s' does not resolve to a file (it may be a directory)", decodedCid.String()), nil)
+			return
+		}
+
+		rawBytes, readErr := io.ReadAll(fileReader)
+		if readErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to read content for CID %s: %s", decodedCid.String(), readErr.Error()), nil)
 			return
 		}
 
 		var jsonData interface{}
-		err = json.Unmarshal(stdout.Bytes(), &jsonData)
+		err = json.Unmarshal(rawBytes, &jsonData)
 		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("Failed to unmarshal JSON from CID %s: %s. Raw data: %s", decodedCid.String(), err.Error(), stdout.String()), nil)
+			printJSONResponse(false, fmt.Sprintf("Failed to unmarshal JSON from CID %s: %s. Raw data: %s", decodedCid.String(), err.Error(), string(rawBytes)), nil)
 			return
 		}
 
@@ -479,37 +2291,24 @@ func main() {
 			return
 		}
 
-		// Execute 'ipfs add -Q --cid-version 1 --pin=false' command
-		// -Q for quiet (only CID output)
-		// --cid-version 1 for CIDv1
-		// --pin=false as add_json typically doesn't pin by default, pinning is a separate step.
-		cmd := exec.Command("ipfs", "add", "-Q", "--cid-version", "1", "--pin=false")
-		cmd.Stdin = bytes.NewReader(jsonDataBytes) // Pipe jsonDataBytes to stdin of 'ipfs add'
+		// CIDv1, unpinned: same shape as 'ipfs add -Q --cid-version 1 --pin=false',
+		// but via the already-connected node handle instead of forking 'ipfs add'.
+		ctxAddJSON, cancelAddJSON := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelAddJSON()
 
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs add' for JSON data: %s", err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		addedPath, addErr := node.Unixfs().Add(ctxAddJSON, files.NewBytesFile(jsonDataBytes), options.Unixfs.CidVersion(1), options.Unixfs.Pin(false))
+		if addErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to add JSON data to IPFS: %s", addErr.Error()), nil)
 			return
 		}
 
-		cidStr := strings.TrimSpace(stdout.String())
-		// Validate the output CID from 'ipfs add -Q'
-		_, err = cid.Decode(cidStr)
-		if err != nil {
-			printJSONResponse(false, fmt.Sprintf("'ipfs add -Q' returned an invalid CID '%s': %s. Stderr: %s", cidStr, err.Error(), stderr.String()), nil)
+		cidValue := addedPath.RootCid()
+		if !cidValue.Defined() {
+			printJSONResponse(false, "Failed to get a defined CID for the added JSON data", nil)
 			return
 		}
 
-		printJSONResponse(true, "", map[string]string{"cid": cidStr})
+		printJSONResponse(true, "", map[string]string{"cid": encodeCid(cidValue)})
 
 	case "gen_ipns_key":
 		genKeyCmd := flag.NewFlagSet("gen_ipns_key", flag.ExitOnError)
@@ -528,50 +2327,29 @@ func main() {
 			return
 		}
 
-		// Command: ipfs key gen <key_name> --type <key_type> --ipns-base base36
-		// The --ipns-base base36 ensures k51q... style keys if the key type supports it (like ed25519).
-		// For RSA, the ID is typically the hash of the public key, represented as a PeerID (Qm...). IPNS name will be derived from this.
-		// The `ipfs key gen` command outputs the PeerID (which is the key's ID) and then the key name.
-		// Example for ed25519: k51qkzoyv89qq9n1x9qsps7qjd5pqph9pv61mgfbk95s6c1gy1xqqb69k mykey
-		// Example for rsa: QmabcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ12345 myrsakey (PeerID format)
-		
-		cmdArgs := []string{"key", "gen", *keyName, "--type", *keyType}
-		// if *keyType == "rsa" { // No explicit size flag for CLI, defaults to 2048 for RSA
-		// 	 cmdArgs = append(cmdArgs, "--size", strconv.Itoa(*keySize)) // Not for CLI
-		// }
-
-		cmd := exec.Command("ipfs", cmdArgs...)
-		
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs key gen %s': %s", *keyName, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		// Generate via the already-connected node handle instead of 'ipfs key gen';
+		// this honors the --api flag and avoids depending on a second 'ipfs' binary on PATH.
+		var keyGenOpt options.KeyGenerateOption
+		switch *keyType {
+		case "rsa":
+			keyGenOpt = options.Key.Type(options.RSAKey)
+		case "ed25519":
+			keyGenOpt = options.Key.Type(options.Ed25519Key)
+		default:
+			printJSONResponse(false, fmt.Sprintf("Unsupported --key-type '%s' (expected rsa or ed25519)", *keyType), nil)
 			return
 		}
 
-		// Output is typically: <key_id_peer_id_format> <key_name>
-		// e.g. QmP2V4N2nJgZ7YxvN7sN9C8LqQZ1Z1Z1Z1Z1Z1Z1Z1Z1Z1Z myrsakey
-		// or   k51qkzoyv89qq9n1x9qsps7qjd5pqph9pv61mgfbk95s6c1gy1xqqb69k myedkey
-		outputParts := strings.Fields(strings.TrimSpace(stdout.String()))
-		if len(outputParts) < 1 { // Should be at least 1 (the ID), name might be omitted if it's `self` or complex names
-			printJSONResponse(false, fmt.Sprintf("'ipfs key gen' produced unexpected output: %s. Stderr: %s", stdout.String(), stderr.String()), nil)
+		ctxKeyGen, cancelKeyGen := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelKeyGen()
+
+		generatedKey, genErr := node.Key().Generate(ctxKeyGen, *keyName, keyGenOpt)
+		if genErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error generating IPNS key '%s': %s", *keyName, genErr.Error()), nil)
 			return
 		}
 
-		keyId := outputParts[0]
-		// Key name from output might be different from input if input was invalid/transformed by ipfs
-		// For simplicity, we return the input keyName as Name, and the output ID as Id.
-		// The ipfshttpclient also returns the input name as 'Name'.
-
-		printJSONResponse(true, "", map[string]string{"Name": *keyName, "Id": keyId})
+		printJSONResponse(true, "", map[string]string{"Name": generatedKey.Name(), "Id": encodeCidString(generatedKey.ID().String())})
 
 	case "list_ipns_keys_cmd":
 		listKeysCmd := flag.NewFlagSet("list_ipns_keys_cmd", flag.ExitOnError)
@@ -582,54 +2360,21 @@ func main() {
 			return
 		}
 
-		// Command: ipfs key list -l
-		// The -l flag gives <key_id> <key_name> format.
-		// --ipns-base base36 might be useful if we want to ensure k51... IDs, but `ipfs key list -l` gives PeerIDs.
-		// The http client returned PeerIDs for `Id`, so `ipfs key list -l` is consistent.
-		cmd := exec.Command("ipfs", "key", "list", "-l")
-
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+		// List via the already-connected node handle instead of 'ipfs key list -l';
+		// removes the dependency on text parsing entirely.
+		ctxKeyList, cancelKeyList := context.WithTimeout(rootCtx, 30*time.Second)
+		defer cancelKeyList()
 
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs key list -l': %s", err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		keys, listErr := node.Key().List(ctxKeyList)
+		if listErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error listing IPNS keys: %s", listErr.Error()), nil)
 			return
 		}
 
-		outputLines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-		var keysList []map[string]string
-
-		for _, line := range outputLines {
-			if line == "" { // Skip empty lines if any
-				continue
-			}
-			parts := strings.Fields(line)
-			if len(parts) >= 2 { // Expecting at least ID and Name
-				keyId := parts[0]
-				keyName := parts[1]
-				// If key name has spaces and was not quoted in output, `parts` might have more elements.
-				// For `ipfs key list -l` the name is usually the last field if no special characters.
-				// If key names can have spaces, `ipfs key list -l --enc=json` would be safer.
-				// Assuming simple key names for now, or that `ipfs key list -l` handles names correctly.
-				keysList = append(keysList, map[string]string{"Id": keyId, "Name": keyName})
-			} else if len(parts) == 1 { // Case for 'self' key which might only show ID
-				// This case is tricky; 'self' usually appears with its ID. If it's just one field, it's likely the ID.
-				// The httpclient output shows 'self' as a name. `ipfs key list -l` output for 'self': <id_of_self> self
-				// So, the len(parts) >= 2 should handle 'self' correctly.
-				// This block might be redundant if `ipfs key list -l` always gives ID and Name for `self`.
-				// For now, we stick to len(parts) >= 2, assuming consistent output from `ipfs key list -l`.
-			}
-		}
-		
-		// Check if `ipfs key list -l --enc=json` is available and preferred for robustness
-		// For now, proceed with text parsing.
+		keysList := make([]map[string]string, 0, len(keys))
+		for _, k := range keys {
+			keysList = append(keysList, map[string]string{"Id": encodeCidString(k.ID().String()), "Name": k.Name()})
+		}
 
 		printJSONResponse(true, "", keysList) // Return the list of key maps as data
 
@@ -637,7 +2382,10 @@ func main() {
 		publishCmd := flag.NewFlagSet("publish_ipns", flag.ExitOnError)
 		keyName := publishCmd.String("key-name", "", "Name of the IPNS key to publish to")
 		ipfsPath := publishCmd.String("path", "", "IPFS path to publish (e.g., /ipfs/CID)")
-		lifetime := publishCmd.String("lifetime", "24h", "Lifetime of the IPNS record (e.g., 24h, 30m)")
+		lifetime := publishCmd.Duration("lifetime", 24*time.Hour, "Lifetime of the IPNS record (e.g., 24h, 30m)")
+		ttl := publishCmd.Duration("ttl", 1*time.Hour, "Suggested caching TTL for resolvers (e.g., 1h, 5m)")
+		allowOffline := publishCmd.Bool("allow-offline", true, "Allow publishing without a live network connection")
+		v1compat := publishCmd.Bool("v1compat", true, "Also include the legacy V1 signature and fields in the IPNS record for older resolvers")
 
 		err := publishCmd.Parse(subcommandArgs)
 		if err != nil {
@@ -658,46 +2406,40 @@ func main() {
 			return
 		}
 
-		// Command: ipfs name publish --key=<key_name> <path> --lifetime=<lifetime_str> --allow-offline=true
-		cmd := exec.Command("ipfs", "name", "publish", "--key="+*keyName, *ipfsPath, "--lifetime="+*lifetime, "--allow-offline=true")
-
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs name publish' for key '%s' to path '%s': %s", *keyName, *ipfsPath, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		p, pathErr := path.NewPath(*ipfsPath)
+		if pathErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error creating IPFS path object for '%s': %s", *ipfsPath, pathErr.Error()), nil)
 			return
 		}
 
-		// Output is: Published to <IPNS_ID_k51...>: /ipfs/<CID>
-		// Example: Published to k51qkzoyv89qq9n1x9qsps7qjd5pqph9pv61mgfbk95s6c1gy1xqqb69k: /ipfs/QmRAGS4fKaj1gS1j1tT8XzYmSLnL8xZTEbhK2mE2e7p2Tj
-		outputStr := strings.TrimSpace(stdout.String())
-		// Regex to capture IPNS ID and the path value
-		re := regexp.MustCompile(`^Published to ([^:]+): (.*)$`)
-		matches := re.FindStringSubmatch(outputStr)
+		ctxPublish, cancelPublish := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelPublish()
 
-		if len(matches) != 3 {
-			printJSONResponse(false, fmt.Sprintf("'ipfs name publish' produced unexpected output: '%s'. Stderr: %s", outputStr, stderr.String()), nil)
-			return
+		publishOpts := []options.NamePublishOption{
+			options.Name.Key(*keyName),
+			options.Name.ValidTime(*lifetime),
+			options.Name.TTL(*ttl),
+			options.Name.AllowOffline(*allowOffline),
+		}
+		if *v1compat {
+			publishOpts = append(publishOpts, options.Name.CompatibleWithV1(true))
 		}
 
-		publishedName := matches[1] // This is the IPNS ID (k51... or PeerID for RSA keys if not using base36)
-		publishedValue := matches[2] // This is the /ipfs/... path
+		ipnsEntry, publishErr := node.Name().Publish(ctxPublish, p, publishOpts...)
+		if publishErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to publish IPNS record for key '%s' to path '%s': %s", *keyName, *ipfsPath, publishErr.Error()), nil)
+			return
+		}
 
-		printJSONResponse(true, "", map[string]string{"Name": publishedName, "Value": publishedValue})
+		// ipnsEntry is the published ipns.Name itself; String() renders it as
+		// a base36 k51... string. The published value is p, not something
+		// recoverable from ipnsEntry, which carries no value path.
+		printJSONResponse(true, "", map[string]string{"Name": encodeCidString(ipnsEntry.String()), "Value": p.String()})
 
 	case "resolve_ipns":
 		resolveCmd := flag.NewFlagSet("resolve_ipns", flag.ExitOnError)
 		ipnsName := resolveCmd.String("ipns-name", "", "IPNS name to resolve (e.g., k51... or /ipns/k51...)")
 		nocache := resolveCmd.Bool("nocache", true, "Resolve without using cached entries")
-		recursive := resolveCmd.Bool("recursive", true, "Resolve recursively until an IPFS path is found")
 
 		err := resolveCmd.Parse(subcommandArgs)
 		if err != nil {
@@ -710,47 +2452,99 @@ func main() {
 			return
 		}
 
-		// Command: ipfs name resolve <ipns_name> --nocache=<bool> -r=<bool>
-		cmdArgs := []string{"name", "resolve", *ipnsName}
-		if *nocache {
-			cmdArgs = append(cmdArgs, "--nocache=true")
+		ctxResolve, cancelResolve := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelResolve()
+
+		data, resolveErr := resolveIPNSName(ctxResolve, node, *ipnsName, *nocache)
+		if resolveErr != nil {
+			printJSONResponse(false, resolveErr.Error(), nil)
+			return
+		}
+
+		printJSONResponse(true, "", data)
+
+	case "resolve_ipns_record":
+		recordCmd := flag.NewFlagSet("resolve_ipns_record", flag.ExitOnError)
+		ipnsName := recordCmd.String("ipns-name", "", "IPNS name whose raw signed record should be fetched (e.g., k51...)")
+
+		if err := recordCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'resolve_ipns_record': %s", err.Error()), nil)
+			return
 		}
-		if *recursive {
-			cmdArgs = append(cmdArgs, "-r=true")
+		if *ipnsName == "" {
+			printJSONResponse(false, "Argument --ipns-name is required", nil)
+			return
 		}
-		
-		cmd := exec.Command("ipfs", cmdArgs...)
 
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+		ctxRecord, cancelRecord := context.WithTimeout(rootCtx, 30*time.Second)
+		defer cancelRecord()
 
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs name resolve %s': %s", *ipnsName, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		rec, pid, fetchErr := fetchIPNSRecord(ctxRecord, node, *ipnsName)
+		if fetchErr != nil {
+			printJSONResponse(false, fetchErr.Error(), nil)
+			return
+		}
+		seq, seqErr := rec.Sequence()
+		validity, validityErr := rec.Validity()
+		validityType, validityTypeErr := rec.ValidityType()
+		ttlVal, ttlErr := rec.TTL()
+		value, valueErr := rec.Value()
+		if seqErr != nil || validityErr != nil || validityTypeErr != nil || ttlErr != nil || valueErr != nil {
+			printJSONResponse(false, fmt.Sprintf("IPNS record for '%s' is missing required fields", *ipnsName), nil)
 			return
 		}
 
-		// Output is the resolved path, e.g., /ipfs/Qm...
-		resolvedPath := strings.TrimSpace(stdout.String())
+		// ipns.UnmarshalRecord only checks structural consistency between the
+		// V1/V2 envelopes; it can't confirm the signature was made by the
+		// claimed key without fetching that key, so do that explicitly (the
+		// same check inspectIPNS uses) instead of reporting a hardcoded true.
+		signatureValid := false
+		if pubKey, pkErr := ipns.ExtractPublicKey(rec, ipns.NameFromPeer(pid)); pkErr == nil {
+			signatureValid = ipns.Validate(rec, pubKey) == nil
+		}
 
-		if !strings.HasPrefix(resolvedPath, "/ipfs/") && !strings.HasPrefix(resolvedPath, "/ipns/") {
-			// This might happen if resolution fails silently or returns something unexpected.
-			// The error from cmd.Run() should ideally catch most failures.
-			printJSONResponse(false, fmt.Sprintf("'ipfs name resolve' returned an unexpected path format: '%s'. Stderr: %s", resolvedPath, stderr.String()), nil)
+		printJSONResponse(true, "", map[string]interface{}{
+			"sequence":        seq,
+			"ttl":             ttlVal.String(),
+			"validity":        validity.Format(time.RFC3339),
+			"validity_type":   ipnsValidityTypeString(validityType),
+			"value":           value.String(),
+			"signature_v2_ok": signatureValid,
+		})
+
+	case "inspect_ipns":
+		// Callers otherwise have no way to distinguish a stale cached record
+		// from a fresh one, or to detect a rollback attack, since
+		// resolve_ipns only surfaces the resolved path. This exposes the full
+		// verified record so they can check sequence/validity themselves.
+		inspectCmd := flag.NewFlagSet("inspect_ipns", flag.ExitOnError)
+		ipnsName := inspectCmd.String("ipns-name", "", "IPNS name to inspect (e.g., k51... or /ipns/k51...)")
+		minSequence := inspectCmd.Uint64("min-sequence", 0, "Fail if the resolved record's sequence number is lower than this")
+
+		if err := inspectCmd.Parse(subcommandArgs); err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error parsing flags for 'inspect_ipns' subcommand: %s", err.Error()), nil)
+			return
+		}
+		if *ipnsName == "" {
+			printJSONResponse(false, "Argument --ipns-name is required", nil)
 			return
 		}
 
-		printJSONResponse(true, "", map[string]string{"Path": resolvedPath})
+		ctxInspect, cancelInspect := context.WithTimeout(rootCtx, 30*time.Second)
+		defer cancelInspect()
+
+		data, inspectErr := inspectIPNS(ctxInspect, node, *ipnsName, *minSequence)
+		if inspectErr != nil {
+			printJSONResponse(false, inspectErr.Error(), nil)
+			return
+		}
+		printJSONResponse(true, "", data)
 
 	case "list_pinned_cids":
 		listPinnedCmd := flag.NewFlagSet("list_pinned_cids", flag.ExitOnError)
-		pinType := listPinnedCmd.String("pin-type", "recursive", "Type of pins to list (recursive, direct, indirect, all)")
+		filterType := listPinnedCmd.String("filter-type", "recursive", "Type of pins to list, narrowed server-side (recursive, direct, indirect, all)")
+		filterName := listPinnedCmd.String("filter-name", "", "Glob pattern pin names must match (client-side, applied as entries stream in)")
+		streamOut := listPinnedCmd.Bool("stream", false, "Emit NDJSON, one pin per line, instead of buffering the whole pinset into a single map")
 
 		err := listPinnedCmd.Parse(subcommandArgs)
 		if err != nil {
@@ -759,57 +2553,64 @@ func main() {
 		}
 
 		validPinTypes := map[string]bool{"recursive": true, "direct": true, "indirect": true, "all": true}
-		if !validPinTypes[*pinType] {
-			printJSONResponse(false, fmt.Sprintf("Invalid --pin-type value: %s. Must be one of recursive, direct, indirect, all.", *pinType), nil)
+		if !validPinTypes[*filterType] {
+			printJSONResponse(false, fmt.Sprintf("Invalid --filter-type value: %s. Must be one of recursive, direct, indirect, all.", *filterType), nil)
 			return
 		}
 
-		// Command: ipfs pin ls --type=<pin_type> (removed -q)
-		cmd := exec.Command("ipfs", "pin", "ls", "--type="+*pinType)
-
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
+		apiMaddr, apiErr := ma.NewMultiaddr(*apiAddrStr)
+		if apiErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid API multiaddress '%s': %s", *apiAddrStr, apiErr.Error()), nil)
+			return
+		}
+		kuboClient, kuboErr := newKuboHTTPClient(apiMaddr, 0) // streamed, so no fixed client-side timeout
+		if kuboErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to build IPFS API client: %s", kuboErr.Error()), nil)
+			return
+		}
 
-		err = cmd.Run()
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs pin ls --type %s': %s", *pinType, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
+		if !*streamOut {
+			cidsWithTypes, listErr := listPinnedCIDs(rootCtx, kuboClient, *filterType, *filterName)
+			if listErr != nil {
+				printJSONResponse(false, listErr.Error(), nil)
+				return
 			}
-			printJSONResponse(false, errMsg, nil)
+			printJSONResponse(true, "", cidsWithTypes)
 			return
 		}
 
-		outputLines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-		cidsWithTypes := make(map[string]string) // Changed from cidsList
-
-		for _, line := range outputLines {
-			trimmedLine := strings.TrimSpace(line)
-			if trimmedLine != "" { 
-				parts := strings.Fields(trimmedLine) // Split by whitespace
-				if len(parts) >= 2 { // Expecting at least CID and Type. Extra info ignored for now.
-					cidStr := parts[0]
-					pinStatusType := parts[1] // This is the pin type (recursive, direct, etc)
+		// --stream needs to emit one line per pin as it arrives rather than
+		// buffering the whole pinset, so it can't go through listPinnedCIDs
+		// (which buffers) and instead decodes the stream itself.
+		ctxPinLs, cancelPinLs := context.WithCancel(rootCtx)
+		defer cancelPinLs()
+		resp, streamErr := kuboClient.PinLsStream(ctxPinLs, *filterType)
+		if streamErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Error listing pins of type '%s': %s", *filterType, streamErr.Error()), nil)
+			return
+		}
+		defer resp.Body.Close()
 
-					// Validate if it's a CID - good practice
-					_, err := cid.Decode(cidStr)
-					if err == nil {
-						cidsWithTypes[cidStr] = pinStatusType
-					} else {
-						fmt.Fprintf(os.Stderr, "Warning: 'ipfs pin ls' output contained non-CID in first part: %s\n", cidStr)
-					}
-				} else if len(parts) == 1 { // If only one part, could be a CID if a line is just a CID (unlikely without -q but handle)
-				    // Or could be an error message from ipfs pin ls if not captured by cmd.Run() error
-				    // For now, we assume valid lines have at least 2 parts.
-				    fmt.Fprintf(os.Stderr, "Warning: 'ipfs pin ls' output line has unexpected format (not enough parts): %s\n", trimmedLine)
-				}
-				// Lines with no parts (empty after trim) are already skipped by the outer if
+		matches := func(name string) bool {
+			if *filterName == "" {
+				return true
 			}
+			ok, matchErr := filepath.Match(*filterName, name)
+			return matchErr == nil && ok
 		}
 
-		printJSONResponse(true, "", cidsWithTypes) // Return the map
+		dec := json.NewDecoder(resp.Body)
+		for dec.More() {
+			var entry pinLsStreamEntry
+			if decErr := dec.Decode(&entry); decErr != nil {
+				fmt.Fprintln(os.Stderr, string(mustJSON(map[string]string{"event": "error", "message": decErr.Error()})))
+				os.Exit(1)
+			}
+			if !matches(entry.Name) {
+				continue
+			}
+			fmt.Println(string(mustJSON(map[string]string{"CID": encodeCidString(entry.Cid), "Type": entry.Type, "Name": entry.Name})))
+		}
 
 	case "dht_find_providers":
 		findProvsCmd := flag.NewFlagSet("dht_find_providers", flag.ExitOnError)
@@ -827,7 +2628,7 @@ func main() {
 			return
 		}
 		// Validate CID
-		_, err = cid.Decode(*cidStr)
+		decodedCid, err := cid.Decode(*cidStr)
 		if err != nil {
 			// If CID is invalid, return success with empty provider list
 			// This aligns with how IPFS findprovs behaves for non-existent (but valid format) CIDs.
@@ -836,36 +2637,25 @@ func main() {
 			return
 		}
 
-		// Command: ipfs routing findprovs --num-providers=<val> <cid>
-		// The timeout for the dht walk itself is managed by the ipfs daemon.
-		// The timeout in the Python client will be for the execution of this Go helper process.
-		cmd := exec.Command("ipfs", "routing", "findprovs", fmt.Sprintf("--num-providers=%d", *numProviders), *cidStr)
-
-		var stdout bytes.Buffer
-		var stderr bytes.Buffer
-		cmd.Stdout = &stdout
-		cmd.Stderr = &stderr
-
-		err = cmd.Run()
-		// `ipfs dht findprovs` can exit 0 even if no providers are found, printing nothing or just a newline.
-		// It exits non-zero for actual errors (e.g. routing error, CID format error before it even starts).
-		if err != nil {
-			errMsg := fmt.Sprintf("Error executing 'ipfs routing findprovs %s': %s", *cidStr, err.Error())
-			if stderr.Len() > 0 {
-				errMsg += fmt.Sprintf(" | IPFS Stderr: %s", stderr.String())
-			}
-			printJSONResponse(false, errMsg, nil)
+		apiMaddr, apiErr := ma.NewMultiaddr(*apiAddrStr)
+		if apiErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Invalid API multiaddress '%s': %s", *apiAddrStr, apiErr.Error()), nil)
+			return
+		}
+		kuboClient, kuboErr := newKuboHTTPClient(apiMaddr, 60*time.Second)
+		if kuboErr != nil {
+			printJSONResponse(false, fmt.Sprintf("Failed to build IPFS API client: %s", kuboErr.Error()), nil)
 			return
 		}
 
-		outputLines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
-		var providersList []string
-		for _, line := range outputLines {
-			trimmedLine := strings.TrimSpace(line)
-			if trimmedLine != "" { // Add only non-empty lines
-				// Basic PeerID validation could be added here if desired (e.g. starts with Qm, 12D, k51)
-				providersList = append(providersList, trimmedLine)
-			}
+		// The timeout for the dht walk itself is managed by the ipfs daemon;
+		// this context bounds how long we wait for the HTTP call overall.
+		ctxFindProvs, cancelFindProvs := context.WithTimeout(rootCtx, 60*time.Second)
+		defer cancelFindProvs()
+		providersList, err := findDHTProviders(ctxFindProvs, kuboClient, decodedCid, *numProviders)
+		if err != nil {
+			printJSONResponse(false, fmt.Sprintf("Error finding providers for '%s': %s", *cidStr, err.Error()), nil)
+			return
 		}
 		// If no providers are found, providersList will be empty, which is a valid successful result.
 		printJSONResponse(true, "", map[string][]string{"providers": providersList})
@@ -873,4 +2663,4 @@ func main() {
 	default:
 		printJSONResponse(false, fmt.Sprintf("Unknown subcommand: '%s'. Args provided: %v", subcommand, subcommandArgs), nil)
 	}
-} 
\ No newline at end of file
+}